@@ -0,0 +1,143 @@
+// Package bigquery implements the Driver interface for Google BigQuery.
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	"google.golang.org/api/iterator"
+)
+
+type Driver struct {
+	client *bigquery.Client
+
+	// project and dataset are the defaults of all queries
+	project string
+	dataset string
+}
+
+const tableName = "schema_migrations"
+
+// BigQuery Driver URL format:
+// bigquery://project/dataset
+//
+// Unqualified tables of migrations refer to the dataset. Credentials
+// are read from the environment, see
+// https://cloud.google.com/docs/authentication/application-default-credentials
+func (driver *Driver) Initialize(url string) error {
+	if !strings.HasPrefix(url, "bigquery://") {
+		return errors.New("invalid bigquery:// scheme")
+	}
+	parts := strings.Split(strings.TrimPrefix(url, "bigquery://"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.New("bigquery:// url requires project and dataset")
+	}
+	driver.project, driver.dataset = parts[0], parts[1]
+
+	client, err := bigquery.NewClient(context.Background(), driver.project)
+	if err != nil {
+		return err
+	}
+	driver.client = client
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) Close() error {
+	return driver.client.Close()
+}
+
+func (driver *Driver) ensureVersionTableExists() error {
+	return driver.run(context.Background(), "CREATE TABLE IF NOT EXISTS "+tableName+" (version INT64 NOT NULL)")
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs the file as a single script job and records the version
+// once the job succeeded. BigQuery doesn't support DDL in transactions,
+// so if a statement fails, the statements before stay applied and the
+// version isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	if err = driver.run(ctx, string(f.Content)); err != nil {
+		return fmt.Errorf("%s: %v", f.FileName, err)
+	}
+
+	version := bigquery.QueryParameter{Name: "version", Value: int64(f.Version)}
+	if f.Direction == direction.Up {
+		err = driver.run(ctx, "INSERT INTO "+tableName+" (version) VALUES (@version)", version)
+	} else if f.Direction == direction.Down {
+		err = driver.run(ctx, "DELETE FROM "+tableName+" WHERE version = @version", version)
+	}
+	return
+}
+
+// query returns a query using the project and dataset of the url
+func (driver *Driver) query(sql string, params ...bigquery.QueryParameter) *bigquery.Query {
+	q := driver.client.Query(sql)
+	q.DefaultProjectID = driver.project
+	q.DefaultDatasetID = driver.dataset
+	q.Parameters = params
+	return q
+}
+
+// run runs sql as a job and waits until it is done
+func (driver *Driver) run(ctx context.Context, sql string, params ...bigquery.QueryParameter) error {
+	job, err := driver.query(sql, params...).Run(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	return status.Err()
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	return driver.run(context.Background(), "DELETE FROM "+tableName+" WHERE version = @version", bigquery.QueryParameter{Name: "version", Value: int64(version)})
+}
+
+// TransactionalDDL returns false.
+// BigQuery doesn't support DDL in transactions.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	it, err := driver.query("SELECT max(version) AS version FROM " + tableName).Read(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	var row struct {
+		Version bigquery.NullInt64 `bigquery:"version"`
+	}
+	switch err := it.Next(&row); {
+	case err == iterator.Done:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return uint64(row.Version.Int64), nil
+	}
+}
+
+func init() {
+	driver.RegisterDriver("bigquery", &Driver{})
+}
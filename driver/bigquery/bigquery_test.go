@@ -0,0 +1,107 @@
+package bigquery
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestInitializeURL(t *testing.T) {
+	for _, url := range []string{
+		"postgres://project/dataset",
+		"bigquery://project",
+		"bigquery://project/",
+		"bigquery://project/dataset/table",
+	} {
+		if err := (&Driver{}).Initialize(url); err == nil {
+			t.Errorf("Expected %v to be invalid", url)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires BIGQUERY_URL, bigquery://project/dataset, of an
+// existing dataset the tests may modify.
+func TestMigrate(t *testing.T) {
+	driverUrl := os.Getenv("BIGQUERY_URL")
+	if driverUrl == "" {
+		t.Skip("BIGQUERY_URL not set")
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// prepare clean dataset
+	ctx := context.Background()
+	for _, table := range []string{"yolo", tableName} {
+		if err := d.run(ctx, "DROP TABLE IF EXISTS "+table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.ensureVersionTableExists(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INT64, msg STRING);
+				INSERT INTO yolo VALUES (1, 'a;b');
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+}
@@ -2,10 +2,10 @@
 package cassandra
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
@@ -18,27 +18,10 @@ type Driver struct {
 	session *gocql.Session
 }
 
-const (
-	tableName  = "schema_migrations"
-	versionRow = 1
-)
-
-type counterStmt bool
-
-func (c counterStmt) String() string {
-	sign := ""
-	if bool(c) {
-		sign = "+"
-	} else {
-		sign = "-"
-	}
-	return "UPDATE " + tableName + " SET version = version " + sign + " 1 where versionRow = ?"
-}
+const tableName = "schema_migrations"
 
-const (
-	up   counterStmt = true
-	down counterStmt = false
-)
+// oldTableName holds the version of a counter table while it's converted.
+const oldTableName = tableName + "_counter"
 
 // Cassandra Driver URL format:
 // cassandra://host:port/keyspace?protocol=version
@@ -47,6 +30,9 @@ const (
 // cassandra://localhost/SpaceOfKeys?protocol=4
 func (driver *Driver) Initialize(rawurl string) error {
 	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
 
 	cluster := gocql.NewCluster(u.Host)
 	cluster.Keyspace = u.Path[1:len(u.Path)]
@@ -83,7 +69,7 @@ func (driver *Driver) Initialize(rawurl string) error {
 		return err
 	}
 
-	if err := driver.ensureVersionTableExists(); err != nil {
+	if err := driver.ensureVersionTableExists(cluster.Keyspace); err != nil {
 		return err
 	}
 	return nil
@@ -94,76 +80,147 @@ func (driver *Driver) Close() error {
 	return nil
 }
 
-func (driver *Driver) ensureVersionTableExists() error {
-	err := driver.session.Query("CREATE TABLE IF NOT EXISTS " + tableName + " (version counter, versionRow bigint primary key);").Exec()
+// ensureVersionTableExists creates the version table, which has a row
+// per applied version. Earlier versions of this driver kept a counter
+// in the table instead. Cassandra doesn't change column types or rename
+// tables, so the counter is first copied to oldTableName, then the old
+// table is replaced and the version copied back. If this is interrupted,
+// the next call picks up from oldTableName.
+func (driver *Driver) ensureVersionTableExists(keyspace string) error {
+	km, err := driver.session.KeyspaceMetadata(keyspace)
 	if err != nil {
 		return err
 	}
-
-	_, err = driver.Version()
-	if err != nil {
-		driver.session.Query(up.String(), versionRow).Exec()
+	_, convert := km.Tables[oldTableName]
+	if table, ok := km.Tables[tableName]; ok {
+		if _, ok := table.Columns["versionrow"]; ok {
+			if err := driver.saveCounter(); err != nil {
+				return fmt.Errorf("converting %s.%s: %v", keyspace, tableName, err)
+			}
+			convert = true
+		}
 	}
 
+	if err := driver.session.Query("CREATE TABLE IF NOT EXISTS " + tableName + " (version bigint primary key);").Exec(); err != nil {
+		return err
+	}
+	if err := driver.session.AwaitSchemaAgreement(context.Background()); err != nil {
+		return err
+	}
+	if convert {
+		if err := driver.restoreCounter(); err != nil {
+			return fmt.Errorf("converting %s.%s: %v", keyspace, tableName, err)
+		}
+	}
 	return nil
 }
 
-func (driver *Driver) FilenameExtension() string {
-	return "cql"
-}
+// saveCounter copies the version of the counter table to oldTableName
+// and drops the counter table.
+func (driver *Driver) saveCounter() error {
+	var version int64
+	err := driver.session.Query("SELECT version FROM " + tableName + " WHERE versionRow = 1").Scan(&version)
+	if err != nil && err != gocql.ErrNotFound {
+		return err
+	}
 
-func (driver *Driver) version(d direction.Direction, invert bool) error {
-	var stmt counterStmt
-	switch d {
-	case direction.Up:
-		stmt = up
-	case direction.Down:
-		stmt = down
+	if err := driver.session.Query("CREATE TABLE IF NOT EXISTS " + oldTableName + " (version bigint primary key);").Exec(); err != nil {
+		return err
 	}
-	if invert {
-		stmt = !stmt
+	if err := driver.session.AwaitSchemaAgreement(context.Background()); err != nil {
+		return err
+	}
+	if version > 0 {
+		if err := driver.session.Query("INSERT INTO "+oldTableName+" (version) VALUES (?)", version).Exec(); err != nil {
+			return err
+		}
 	}
-	return driver.session.Query(stmt.String(), versionRow).Exec()
+
+	if err := driver.session.Query("DROP TABLE " + tableName).Exec(); err != nil {
+		return err
+	}
+	return driver.session.AwaitSchemaAgreement(context.Background())
 }
 
-func (driver *Driver) Migrate(f file.File, pipe chan interface{}) {
-	var err error
-	defer func() {
-		if err != nil {
-			// Invert version direction if we couldn't apply the changes for some reason.
-			if err := driver.version(f.Direction, true); err != nil {
-				pipe <- err
-			}
-			pipe <- err
+// restoreCounter copies the version saved by saveCounter to the version
+// table and drops oldTableName.
+func (driver *Driver) restoreCounter() error {
+	var version int64
+	iter := driver.session.Query("SELECT version FROM " + oldTableName).Iter()
+	for iter.Scan(&version) {
+		if err := driver.session.Query("INSERT INTO "+tableName+" (version) VALUES (?)", version).Exec(); err != nil {
+			iter.Close()
+			return err
 		}
-		close(pipe)
-	}()
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
 
-	pipe <- f
-	if err = driver.version(f.Direction, false); err != nil {
-		return
+	if err := driver.session.Query("DROP TABLE " + oldTableName).Exec(); err != nil {
+		return err
 	}
+	return driver.session.AwaitSchemaAgreement(context.Background())
+}
 
+func (driver *Driver) FilenameExtension() string {
+	return "cql"
+}
+
+// Migrate runs the statements of the file one by one, since Cassandra
+// doesn't support transactions, and waits until all nodes agree on the
+// schema before recording the version. If a statement fails or the
+// nodes don't agree within the MaxWaitSchemaAgreement of gocql, the
+// statements before stay applied and the version isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
 	if err = f.ReadContent(); err != nil {
 		return
 	}
 
-	for _, query := range strings.Split(string(f.Content), ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
+	for _, stmt := range file.SplitStatements(f.Content) {
+		if err = driver.session.Query(string(stmt)).Exec(); err != nil {
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, stmt)
 		}
+	}
 
-		if err = driver.session.Query(query).Exec(); err != nil {
-			return
-		}
+	if err = driver.session.AwaitSchemaAgreement(context.Background()); err != nil {
+		return fmt.Errorf("%s: %v", f.FileName, err)
+	}
+
+	switch f.Direction {
+	case direction.Up:
+		err = driver.session.Query("INSERT INTO "+tableName+" (version) VALUES (?)", int64(f.Version)).Exec()
+	case direction.Down:
+		err = driver.session.Query("DELETE FROM "+tableName+" WHERE version = ?", int64(f.Version)).Exec()
 	}
+	return
 }
 
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	return driver.session.Query("DELETE FROM "+tableName+" WHERE version = ?", int64(version)).Exec()
+}
+
+// TransactionalDDL returns false.
+// Cassandra runs each statement on its own.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+// Version returns the highest version in the version table. Cassandra
+// doesn't sort across partitions, so all versions are read.
 func (driver *Driver) Version() (uint64, error) {
-	var version int64
-	err := driver.session.Query("SELECT version FROM "+tableName+" WHERE versionRow = ?", versionRow).Scan(&version)
-	return uint64(version) - 1, err
+	var version, max int64
+	iter := driver.session.Query("SELECT version FROM " + tableName).Iter()
+	for iter.Scan(&version) {
+		if version > max {
+			max = version
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return 0, err
+	}
+	return uint64(max), nil
 }
 
 func init() {
@@ -9,13 +9,17 @@ import (
 	"github.com/gocql/gocql"
 	"github.com/chr4/migrate/file"
 	"github.com/chr4/migrate/migrate/direction"
-	pipep "github.com/chr4/migrate/pipe"
 )
 
+// TestMigrate runs some additional tests on Migrate().
+// It requires CASSANDRA_PORT_9042_TCP_ADDR and CASSANDRA_PORT_9042_TCP_PORT.
 func TestMigrate(t *testing.T) {
 	var session *gocql.Session
 
 	host := os.Getenv("CASSANDRA_PORT_9042_TCP_ADDR")
+	if host == "" {
+		t.Skip("CASSANDRA_PORT_9042_TCP_ADDR not set")
+	}
 	port := os.Getenv("CASSANDRA_PORT_9042_TCP_PORT")
 	driverUrl := "cassandra://" + host + ":" + port + "/system"
 
@@ -39,8 +43,13 @@ func TestMigrate(t *testing.T) {
 	if err := session.Query(`CREATE KEYSPACE IF NOT EXISTS migrate WITH REPLICATION = {'class': 'SimpleStrategy', 'replication_factor': 1};`).Exec(); err != nil {
 		t.Fatal(err)
 	}
-	cluster.Keyspace = "migrate"
-	session, err = cluster.CreateSession()
+	if err := session.Query(`DROP TABLE IF EXISTS migrate.yolo`).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Query(`DROP TABLE IF EXISTS migrate.` + tableName).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
 	driverUrl = "cassandra://" + host + ":" + port + "/migrate"
 
 	d := &Driver{}
@@ -88,29 +97,81 @@ func TestMigrate(t *testing.T) {
 		},
 	}
 
-	pipe := pipep.New()
-	go d.Migrate(files[0], pipe)
-	errs := pipep.ReadErrors(pipe)
-	if len(errs) > 0 {
-		t.Fatal(errs)
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
 	}
 
-	pipe = pipep.New()
-	go d.Migrate(files[1], pipe)
-	errs = pipep.ReadErrors(pipe)
-	if len(errs) > 0 {
-		t.Fatal(errs)
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
 	}
 
-	pipe = pipep.New()
-	go d.Migrate(files[2], pipe)
-	errs = pipep.ReadErrors(pipe)
-	if len(errs) == 0 {
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Migrate(files[2]); err == nil {
 		t.Error("Expected test case to fail")
 	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
 
 	if err := d.Close(); err != nil {
 		t.Fatal(err)
 	}
+}
+
+// TestConvertCounterTable checks that Initialize converts the counter
+// table of earlier versions of the driver.
+// It requires CASSANDRA_PORT_9042_TCP_ADDR and CASSANDRA_PORT_9042_TCP_PORT.
+func TestConvertCounterTable(t *testing.T) {
+	host := os.Getenv("CASSANDRA_PORT_9042_TCP_ADDR")
+	if host == "" {
+		t.Skip("CASSANDRA_PORT_9042_TCP_ADDR not set")
+	}
+	port := os.Getenv("CASSANDRA_PORT_9042_TCP_PORT")
 
+	cluster := gocql.NewCluster(host + ":" + port)
+	cluster.Keyspace = "system"
+	cluster.Consistency = gocql.All
+	cluster.Timeout = 1 * time.Minute
+
+	session, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE KEYSPACE IF NOT EXISTS migrate WITH REPLICATION = {'class': 'SimpleStrategy', 'replication_factor': 1};`,
+		`DROP TABLE IF EXISTS migrate.` + tableName,
+		`DROP TABLE IF EXISTS migrate.` + oldTableName,
+		`CREATE TABLE migrate.` + tableName + ` (version counter, versionRow bigint primary key);`,
+		`UPDATE migrate.` + tableName + ` SET version = version + 3 where versionRow = 1`,
+	} {
+		if err := session.Query(stmt).Exec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session.Close()
+
+	d := &Driver{}
+	if err := d.Initialize("cassandra://" + host + ":" + port + "/migrate"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	if version, err := d.Version(); err != nil || version != 3 {
+		t.Fatalf("Expected version 3, got %v (%v)", version, err)
+	}
+	km, err := d.session.KeyspaceMetadata("migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := km.Tables[oldTableName]; ok {
+		t.Errorf("Expected %s to be dropped", oldTableName)
+	}
 }
@@ -0,0 +1,56 @@
+package driver
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// pollInterval is the time between two polls of WaitForObjects
+var pollInterval = 200 * time.Millisecond
+
+// ddlObjectRegex matches the optional schema and the name of tables and
+// views created or altered by a statement, and the new name of renames
+var ddlObjectRegex = regexp.MustCompile(`(?i)\b(?:CREATE|ALTER)\s+(?:OR\s+REPLACE\s+)?(?:TABLE|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?((?:(?:"[^"]+"|\w+)\.)?)("[^"]+"|\w+)(?:\s+RENAME\s+TO\s+("[^"]+"|\w+))?`)
+
+// objectNames returns the names of the tables and views created or
+// altered by content in order of appearance. Renamed objects are
+// returned by their new name, in the schema of the old one.
+func objectNames(content []byte) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range ddlObjectRegex.FindAllSubmatch(content, -1) {
+		name := string(m[1]) + string(m[2])
+		if len(m[3]) > 0 {
+			name = string(m[1]) + string(m[3])
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// WaitForObjects polls w until all tables and views created or altered
+// by content are visible. It returns an error if they aren't visible
+// within timeout.
+func WaitForObjects(w ConsistencyWaiter, content []byte, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, name := range objectNames(content) {
+		for {
+			visible, err := w.ObjectVisible(name)
+			if err != nil {
+				return err
+			}
+			if visible {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s is not visible in the catalog after %v", name, timeout)
+			}
+			time.Sleep(pollInterval)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCatalog makes objects visible after a delay
+type fakeCatalog struct {
+	visibleAt time.Time
+	polled    []string
+}
+
+func (c *fakeCatalog) SetConsistencyTimeout(timeout time.Duration) {}
+
+func (c *fakeCatalog) ObjectVisible(name string) (bool, error) {
+	c.polled = append(c.polled, name)
+	return time.Now().After(c.visibleAt), nil
+}
+
+func TestObjectNames(t *testing.T) {
+	names := objectNames([]byte(`
+		CREATE TABLE IF NOT EXISTS yolo (id int);
+		create view doc.yolo_view as select id from yolo;
+		ALTER TABLE "Yolo2" ADD COLUMN name text;
+		ALTER TABLE yolo ADD COLUMN name text;
+		DROP TABLE old;
+		ALTER TABLE doc.foo RENAME TO bar;
+		INSERT INTO yolo (id) VALUES (1);`))
+	expect := []string{"yolo", "doc.yolo_view", `"Yolo2"`, "doc.bar"}
+	if strings.Join(names, ",") != strings.Join(expect, ",") {
+		t.Errorf("Expected %v, got %v", expect, names)
+	}
+}
+
+func TestWaitForObjects(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = time.Millisecond
+
+	content := []byte("CREATE TABLE yolo (id int); CREATE TABLE yolo2 (id int);")
+	c := &fakeCatalog{visibleAt: time.Now().Add(20 * time.Millisecond)}
+	if err := WaitForObjects(c, content, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.polled) < 3 || c.polled[0] != "yolo" || c.polled[len(c.polled)-1] != "yolo2" {
+		t.Errorf("Expected yolo to be polled until visible, then yolo2, got %v", c.polled)
+	}
+
+	c = &fakeCatalog{visibleAt: time.Now().Add(time.Hour)}
+	if err := WaitForObjects(c, content, 20*time.Millisecond); err == nil || !strings.Contains(err.Error(), "yolo is not visible") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+
+	c = &fakeCatalog{}
+	if err := WaitForObjects(c, []byte("INSERT INTO yolo VALUES (1)"), time.Second); err != nil || len(c.polled) != 0 {
+		t.Errorf("Expected nothing to be polled, got %v (%v)", c.polled, err)
+	}
+}
@@ -0,0 +1,184 @@
+// Package crate implements the Driver interface for CrateDB.
+package crate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	_ "github.com/lib/pq"
+)
+
+type Driver struct {
+	db *sql.DB
+
+	// consistencyTimeout is the time to wait for created
+	// objects to become visible, see SetConsistencyTimeout
+	consistencyTimeout time.Duration
+}
+
+const tableName = "schema_migrations"
+
+// waitForObjects is driver.WaitForObjects, which is
+// shadowed by the receivers
+var waitForObjects = driver.WaitForObjects
+
+// schemes are the url schemes the driver is registered for
+var schemes = []string{"crate", "cratedb"}
+
+// CrateDB Driver URL format:
+// crate://user@host:port/schema?options
+// cratedb://user@host:port/schema?options
+//
+// CrateDB speaks the postgres wire protocol, options are passed
+// to github.com/lib/pq. The schema defaults to doc.
+func (driver *Driver) Initialize(url string) error {
+	var rest string
+	for _, scheme := range schemes {
+		if strings.HasPrefix(url, scheme+"://") {
+			rest = strings.TrimPrefix(url, scheme+"://")
+			break
+		}
+	}
+	if rest == "" {
+		return errors.New("invalid crate:// scheme")
+	}
+
+	db, err := sql.Open("postgres", "postgres://"+rest)
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	driver.db = db
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) Close() error {
+	if err := driver.db.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) ensureVersionTableExists() error {
+	if _, err := driver.db.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version bigint PRIMARY KEY)"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs the statements of the file one by one, since CrateDB
+// doesn't support transactions. If a statement fails, the statements
+// before stay applied and the version isn't recorded. With a consistency
+// timeout, created and altered tables and views must be visible in the
+// catalog before the version is recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	for _, stmt := range file.SplitStatements(f.Content) {
+		if _, err = driver.db.Exec(string(stmt)); err != nil {
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, stmt)
+		}
+	}
+
+	if driver.consistencyTimeout > 0 {
+		if err = waitForObjects(driver, f.Content, driver.consistencyTimeout); err != nil {
+			return fmt.Errorf("%s: %v", f.FileName, err)
+		}
+	}
+
+	if f.Direction == direction.Up {
+		_, err = driver.db.Exec("INSERT INTO "+tableName+" (version) VALUES ($1)", f.Version)
+	} else if f.Direction == direction.Down {
+		_, err = driver.db.Exec("DELETE FROM "+tableName+" WHERE version = $1", f.Version)
+	}
+	if err != nil {
+		return
+	}
+
+	// make the version visible to Version
+	_, err = driver.db.Exec("REFRESH TABLE " + tableName)
+	return
+}
+
+// SetConsistencyTimeout sets how long Migrate waits for created
+// and altered tables and views to become visible.
+func (driver *Driver) SetConsistencyTimeout(timeout time.Duration) {
+	driver.consistencyTimeout = timeout
+}
+
+// ObjectVisible reports whether the table or view name is listed in
+// information_schema.tables. Unqualified names are looked up in the
+// current schema.
+func (driver *Driver) ObjectVisible(name string) (bool, error) {
+	parts := strings.SplitN(name, ".", 2)
+	query := "SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA AND table_name = $1"
+	args := []interface{}{identifier(parts[0])}
+	if len(parts) == 2 {
+		query = "SELECT count(*) FROM information_schema.tables WHERE table_schema = $1 AND table_name = $2"
+		args = []interface{}{identifier(parts[0]), identifier(parts[1])}
+	}
+
+	var count int
+	if err := driver.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// identifier returns the catalog name of an identifier. Quoted
+// identifiers keep their case, others are folded to lower case.
+func identifier(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1]
+	}
+	return strings.ToLower(s)
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	_, err := driver.db.Exec("DELETE FROM "+tableName+" WHERE version = $1", version)
+	return err
+}
+
+// TransactionalDDL returns false.
+// CrateDB doesn't support transactions.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	var version uint64
+	err := driver.db.QueryRow("SELECT version FROM " + tableName + " ORDER BY version DESC LIMIT 1").Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return version, nil
+	}
+}
+
+func init() {
+	for _, scheme := range schemes {
+		driver.RegisterDriver(scheme, &Driver{})
+	}
+}
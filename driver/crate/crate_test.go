@@ -0,0 +1,104 @@
+package crate
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires CRATE_HOST and CRATE_PORT of a CrateDB server.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("CRATE_HOST")
+	if host == "" {
+		t.Skip("CRATE_HOST not set")
+	}
+	port := os.Getenv("CRATE_PORT")
+	driverUrl := "crate://crate@" + host + ":" + port + "/doc?sslmode=disable"
+
+	// prepare clean database
+	connection, err := sql.Open("postgres", "postgres://crate@"+host+":"+port+"/doc?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	for _, table := range []string{"yolo", tableName} {
+		if _, err := connection.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.SetConsistencyTimeout(10 * time.Second)
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content:   []byte(`CREATE TABLE yolo (id integer);`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content:   []byte(`DROP TABLE yolo;`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content:   []byte(`CREATE TABLE error (id THIS WILL CAUSE AN ERROR)`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if visible, err := d.ObjectVisible("yolo"); err != nil || !visible {
+		t.Errorf("Expected yolo to be visible, got %v (%v)", visible, err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for name, expect := range map[string]string{"Yolo": "yolo", `"Yolo"`: "Yolo", "doc": "doc"} {
+		if got := identifier(name); got != expect {
+			t.Errorf("identifier(%v) = %v, expected %v", name, got, expect)
+		}
+	}
+}
+
+func TestInitializeScheme(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("postgres://crate@localhost:5432/doc"); err == nil || err.Error() != "invalid crate:// scheme" {
+		t.Errorf("Expected invalid scheme error, got %v", err)
+	}
+}
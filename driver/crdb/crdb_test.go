@@ -0,0 +1,149 @@
+package crdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	"github.com/lib/pq"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{fmt.Errorf("wrapped: %w", &pq.Error{Code: "40001"}), true},
+		{&pq.Error{Code: "42P01"}, false},
+		{errors.New("40001"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.expect {
+			t.Errorf("Expected %v for %v, got %v", tt.expect, tt.err, got)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires COCKROACH_HOST of an insecure node.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("COCKROACH_HOST")
+	if host == "" {
+		t.Skip("COCKROACH_HOST not set")
+	}
+	driverUrl := "cockroachdb://root@" + host + "/defaultdb?sslmode=disable"
+
+	// prepare clean database
+	connection, err := sql.Open("postgres", "postgres://root@"+host+":26257/defaultdb?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	for _, table := range []string{"yolo", tableName, lockTableName} {
+		if _, err := connection.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var retries int
+	d.OnRetry(func(f file.File, attempt int, err error) { retries++ })
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INT PRIMARY KEY);
+				SELECT crdb_internal.force_retry('500ms');
+				INSERT INTO yolo VALUES (1);
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if retries == 0 {
+		t.Error("Expected forced retries")
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	other := &Driver{}
+	if err := other.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	lockPollInterval = 10 * time.Millisecond
+	locked := make(chan error)
+	go func() { locked <- other.Lock() }()
+	select {
+	case err := <-locked:
+		t.Fatalf("Expected Lock to block, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
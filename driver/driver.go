@@ -2,8 +2,12 @@
 package driver
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	neturl "net/url" // alias to allow `url string` func signature in New
+	"reflect"
+	"time"
 
 	"github.com/chr4/migrate/file"
 )
@@ -34,6 +38,373 @@ type Driver interface {
 	Version() (uint64, error)
 }
 
+// FilenameExtensionsDriver is an optional interface a driver can
+// implement if it accepts migration files with several extensions.
+type FilenameExtensionsDriver interface {
+
+	// FilenameExtensions returns all accepted extensions of the
+	// migration files. The first one is used for new files and
+	// should equal FilenameExtension. The returned strings must
+	// not begin with a dot.
+	FilenameExtensions() []string
+}
+
+// FilenameExtensions returns all filename extensions accepted by d.
+// Drivers not implementing FilenameExtensionsDriver accept
+// FilenameExtension only.
+func FilenameExtensions(d Driver) []string {
+	if fd, ok := d.(FilenameExtensionsDriver); ok {
+		if extensions := fd.FilenameExtensions(); len(extensions) > 0 {
+			return extensions
+		}
+	}
+	return []string{d.FilenameExtension()}
+}
+
+// Locker is an optional interface a driver can implement to guard
+// migrations against concurrent runs.
+type Locker interface {
+
+	// Lock acquires an exclusive lock. It blocks until the lock is
+	// available.
+	Lock() error
+
+	// Unlock releases the lock acquired by Lock.
+	Unlock() error
+}
+
+// Checksummer is an optional interface a driver can implement
+// if it stores file.File.Checksum of applied up migrations.
+type Checksummer interface {
+
+	// Checksums returns the stored checksums of all applied
+	// migrations, keyed by version. Versions applied without
+	// a checksum may be omitted.
+	Checksums() (map[uint64]string, error)
+}
+
+// LatestChecksummer is an optional interface a Checksummer can implement
+// to read the checksum of the latest version only, see migrate.VerifyLatest.
+type LatestChecksummer interface {
+
+	// LatestChecksum returns the most recently applied version and
+	// its stored checksum, which is empty for versions applied
+	// without a checksum.
+	LatestChecksum() (version uint64, checksum string, err error)
+}
+
+// VersionInfoDriver is an optional interface a driver can implement
+// if it stores the migration name along with the version.
+type VersionInfoDriver interface {
+
+	// VersionInfo returns the current migration version and
+	// the name of the migration. The name is empty for versions
+	// applied without a name.
+	VersionInfo() (version uint64, name string, err error)
+}
+
+// SchemaLister is an optional interface a driver can implement
+// if the database supports multiple schemas.
+type SchemaLister interface {
+
+	// Schemas returns the names of all schemas matching the
+	// SQL LIKE pattern, sorted by name.
+	Schemas(pattern string) ([]string, error)
+}
+
+// GoMigrator is an optional interface a transactional driver can
+// implement to run migrations written in Go, see file.GoMigrationFunc.
+type GoMigrator interface {
+
+	// MigrateGo calls f.GoFunc with a transaction, records the
+	// version in the same transaction and commits it.
+	MigrateGo(f file.File) error
+}
+
+// ConsistencyWaiter is an optional interface for drivers of distributed
+// databases, where DDL propagates asynchronously. Their Migrate waits until
+// the tables and views created or altered by a file are visible before
+// recording the version, see WaitForObjects.
+type ConsistencyWaiter interface {
+
+	// SetConsistencyTimeout sets how long Migrate waits for
+	// objects to become visible. 0 disables waiting.
+	SetConsistencyTimeout(timeout time.Duration)
+
+	// ObjectVisible reports whether the table or view name,
+	// optionally schema qualified, is visible in the catalog.
+	ObjectVisible(name string) (bool, error)
+}
+
+// Warmer is an optional interface a driver can implement to prepare
+// its connection before migrating, see migrate.Warmup.
+type Warmer interface {
+
+	// Warmup establishes a connection and loads caches, so that
+	// the first migration runs faster. The connection must be kept
+	// until Close and reused by Initialize with the same url.
+	Warmup() error
+}
+
+// VersionRemover is an optional interface a driver can implement
+// to remove a version without running a migration, e.g. of versions
+// without down migration, see migrate.SkipMissingDown.
+type VersionRemover interface {
+
+	// RemoveVersion removes version from the version table.
+	RemoveVersion(version uint64) error
+}
+
+// ForceDownDriver is an optional interface a driver can implement
+// to forget applied versions without running down migrations.
+type ForceDownDriver interface {
+
+	// ForceDown removes all versions greater than version from the
+	// version table in a single transaction.
+	ForceDown(version uint64) error
+}
+
+// JSONValidatingDriver is an optional interface a driver with JSON
+// migration files can implement, so malformed files are reported
+// with the offending field before any of them is applied.
+type JSONValidatingDriver interface {
+
+	// JSONSchema returns the JSON schema all migration files must match.
+	JSONSchema() []byte
+}
+
+// ExtensionCreator is an optional interface a driver can implement if
+// it checks the extensions required by migrations before running them.
+type ExtensionCreator interface {
+
+	// SetAutoCreateExtensions sets whether missing required
+	// extensions are created instead of failing the migration.
+	SetAutoCreateExtensions(enable bool)
+}
+
+// ContextMigrator is an optional interface a driver can implement
+// to abort a running migration, see migrate.SetRunTimeout.
+type ContextMigrator interface {
+
+	// MigrateContext is like Migrate, but aborts the
+	// migration and rolls it back once ctx is done.
+	MigrateContext(ctx context.Context, f file.File) error
+}
+
+// RefreshDriver is an optional interface a driver can implement to
+// re-run the content of an applied migration, e.g. one re-creating a view.
+type RefreshDriver interface {
+
+	// Refresh runs the content of f in a transaction
+	// without recording its version.
+	Refresh(f file.File) error
+}
+
+// ObjectLister is an optional interface a driver can implement to
+// list the objects in the database, e.g. to verify that rolling back
+// all migrations left nothing behind.
+type ObjectLister interface {
+
+	// ListObjects returns descriptions of all user objects like
+	// "table public.foo", sorted.
+	ListObjects() ([]string, error)
+}
+
+// TransactionalDDLDriver is an optional interface a driver can implement
+// to report whether schema changes are transactional. Drivers not
+// implementing it are treated as non-transactional.
+type TransactionalDDLDriver interface {
+
+	// TransactionalDDL reports whether a failed migration is
+	// rolled back completely, including its DDL statements.
+	TransactionalDDL() bool
+}
+
+// TransactionalDDL reports whether d's schema changes are transactional.
+func TransactionalDDL(d Driver) bool {
+	if td, ok := d.(TransactionalDDLDriver); ok {
+		return td.TransactionalDDL()
+	}
+	return false
+}
+
+// DefaultURLer is an optional interface a driver can implement to
+// complete and validate urls before Initialize is called.
+type DefaultURLer interface {
+
+	// NormalizeURL fills in defaults like the port and returns an
+	// error describing the expected format if url is malformed.
+	NormalizeURL(url string) (string, error)
+}
+
+// NormalizeURL returns url normalized by d, see DefaultURLer.
+// Urls of other drivers are returned unchanged.
+func NormalizeURL(d Driver, url string) (string, error) {
+	if du, ok := d.(DefaultURLer); ok {
+		return du.NormalizeURL(url)
+	}
+	return url, nil
+}
+
+// AppliedMigration describes an applied migration as
+// stored by the driver.
+type AppliedMigration struct {
+	Version uint64
+
+	// name of the migration, if stored
+	Name string
+
+	// checksum of the up migration file, if stored
+	Checksum string
+
+	// time the migration was applied, zero if unknown
+	AppliedAt time.Time
+
+	// label of the run which applied the migration, if stored
+	RunLabel string
+}
+
+// VersionsDriver is an optional interface a driver can implement
+// to list all applied migrations, not just the current version.
+type VersionsDriver interface {
+
+	// Versions returns all applied migrations sorted by version.
+	Versions() ([]AppliedMigration, error)
+}
+
+// DirtyDriver is an optional interface a driver can implement if a
+// failed migration can leave the database partially migrated. Such
+// a version is marked dirty until it has been fixed manually.
+type DirtyDriver interface {
+
+	// Dirty returns the current version and whether it is dirty.
+	Dirty() (dirty bool, version uint64, err error)
+
+	// ClearDirty clears the dirty flag, keeping the version.
+	ClearDirty() error
+}
+
+// NoticeDriver is an optional interface a driver can implement if
+// the database reports notices, like "table already exists, skipping".
+type NoticeDriver interface {
+
+	// FailOnNotice makes Migrate treat notices raised by a migration
+	// as errors and roll the migration back.
+	FailOnNotice(enable bool)
+}
+
+// RetryDriver is an optional interface a driver can implement if it
+// retries migrations failing with transient errors, like deadlocks.
+type RetryDriver interface {
+
+	// OnRetry sets a callback which is called before f is retried.
+	// attempt is the 1-based number of the failed attempt.
+	OnRetry(fn func(f file.File, attempt int, err error))
+}
+
+// StatementTimer is an optional interface a driver can implement if it
+// runs the statements of a file one by one, see file.SplitStatements.
+type StatementTimer interface {
+
+	// OnStatement sets a callback which is called after each statement
+	// with its 0-based index in the file and the time it took.
+	OnStatement(fn func(f file.File, index int, d time.Duration))
+}
+
+// Explainer is an optional interface a driver can implement if it
+// captures the query plans of files with a "-- migrate:explain" directive.
+type Explainer interface {
+
+	// OnExplain sets a callback which is called after each statement
+	// of such a file with its 0-based index and its plan. Statements
+	// which can't be explained, like DDL, have an empty plan and
+	// reason tells why.
+	OnExplain(fn func(f file.File, index int, plan, reason string))
+}
+
+// CloneDriver is an optional interface a driver can implement if it
+// can copy its database including all data, see migrate.UpAgainstClone.
+type CloneDriver interface {
+
+	// CreateClone creates a copy of the database and returns its url.
+	CreateClone() (url string, err error)
+
+	// DropClone drops a copy created by CreateClone.
+	// All connections to it must be closed before.
+	DropClone(url string) error
+}
+
+// BatchDriver is an optional interface a driver can implement if it
+// runs batched migrations, which repeat their content in separate
+// transactions until it affects no more rows.
+type BatchDriver interface {
+
+	// OnBatchProgress sets a callback which is called after each
+	// committed batch with the total number of rows processed so far.
+	OnBatchProgress(fn func(f file.File, processed int64))
+}
+
+// TwoPhaseCommitDriver is an optional interface a driver can implement
+// to migrate several databases atomically with a two-phase commit.
+type TwoPhaseCommitDriver interface {
+
+	// PrepareMigration runs f in a transaction and prepares it
+	// for commit under the given id, without committing it.
+	PrepareMigration(f file.File, id string) error
+
+	// CommitPrepared commits the transaction prepared under id.
+	CommitPrepared(id string) error
+
+	// RollbackPrepared rolls back the transaction prepared under id.
+	RollbackPrepared(id string) error
+}
+
+// QueryDriver is an optional interface a driver can implement
+// to run arbitrary read queries, e.g. integrity checks.
+type QueryDriver interface {
+
+	// QueryRows returns up to limit rows of query with
+	// all columns formatted as strings.
+	QueryRows(query string, limit int) ([][]string, error)
+}
+
+// SyntaxCheckDriver is an optional interface a driver can implement
+// to validate migration content without applying it.
+type SyntaxCheckDriver interface {
+
+	// CheckSyntax returns an error if content can't be parsed.
+	// It must not change the database.
+	CheckSyntax(content []byte) error
+}
+
+// NewInstance is like New, but returns a new instance of the registered
+// driver instead of the shared one, so that several databases of the
+// same kind can be used at once. The driver must be a pointer to a struct.
+func NewInstance(url string) (Driver, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := GetDriver(u.Scheme)
+	if registered == nil {
+		return nil, fmt.Errorf("Driver '%s' not found.", u.Scheme)
+	}
+	t := reflect.TypeOf(registered)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Driver '%s' can't be instantiated.", u.Scheme)
+	}
+	d := reflect.New(t.Elem()).Interface().(Driver)
+	url, err = NormalizeURL(d, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Initialize(url); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 // New returns Driver and calls Initialize on it
 func New(url string) (Driver, error) {
 	u, err := neturl.Parse(url)
@@ -46,6 +417,10 @@ func New(url string) (Driver, error) {
 		return nil, fmt.Errorf("Driver '%s' not found.", u.Scheme)
 	}
 	verifyFilenameExtension(u.Scheme, d)
+	url, err = NormalizeURL(d, url)
+	if err != nil {
+		return nil, err
+	}
 	if err := d.Initialize(url); err != nil {
 		return nil, err
 	}
@@ -53,6 +428,18 @@ func New(url string) (Driver, error) {
 	return d, nil
 }
 
+// NewWithDB returns a Driver working on an existing *sql.DB.
+// Initialize is not called and closing the driver must not close db.
+func NewWithDB(db *sql.DB) (Driver, error) {
+	fn := getDBDriver(db)
+	if fn == nil {
+		return nil, fmt.Errorf("No driver found for %T.", db.Driver())
+	}
+	d := fn(db)
+	verifyFilenameExtension(fmt.Sprintf("%T", d), d)
+	return d, nil
+}
+
 // verifyFilenameExtension panics if the driver's filename extension
 // is not correct or empty.
 func verifyFilenameExtension(driverName string, d Driver) {
@@ -63,4 +450,12 @@ func verifyFilenameExtension(driverName string, d Driver) {
 	if f[0:1] == "." {
 		panic(fmt.Sprintf("%s.FilenameExtension() returned string must not start with a dot.", driverName))
 	}
+	for _, f := range FilenameExtensions(d) {
+		if f == "" {
+			panic(fmt.Sprintf("%s.FilenameExtensions() returns empty string.", driverName))
+		}
+		if f[0:1] == "." {
+			panic(fmt.Sprintf("%s.FilenameExtensions() returned strings must not start with a dot.", driverName))
+		}
+	}
 }
@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+// DSN holds the connection parameters of a driver url, so that callers
+// don't need to build urls by string concatenation. Scheme is the name
+// of the registered driver, e.g. "postgres". Port 0 is omitted.
+// Database is the path of the url: a database name, or a file name for
+// drivers like sqlite3 which don't need a host. Without a host, relative
+// file names follow the scheme directly, e.g. sqlite3://test.db, which
+// ParseDSN can't tell from a host and returns as Host.
+type DSN struct {
+	Scheme   string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	Params   map[string]string
+}
+
+// String returns the url of the DSN. User, password, database and
+// params are escaped, params are sorted by key.
+func (dsn DSN) String() string {
+	u := neturl.URL{
+		Scheme: dsn.Scheme,
+		Host:   dsn.Host,
+	}
+	if dsn.Port != 0 {
+		u.Host = net.JoinHostPort(dsn.Host, strconv.Itoa(dsn.Port))
+	} else if strings.Contains(dsn.Host, ":") {
+		u.Host = "[" + dsn.Host + "]"
+	}
+	if dsn.Password != "" {
+		u.User = neturl.UserPassword(dsn.User, dsn.Password)
+	} else if dsn.User != "" {
+		u.User = neturl.User(dsn.User)
+	}
+	if dsn.Host != "" && dsn.Database != "" && !strings.HasPrefix(dsn.Database, "/") {
+		u.Path = "/" + dsn.Database
+	} else {
+		u.Path = dsn.Database
+	}
+	if len(dsn.Params) > 0 {
+		query := neturl.Values{}
+		for key, value := range dsn.Params {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// ParseDSN splits url into its connection parameters.
+func ParseDSN(url string) (DSN, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return DSN{}, err
+	}
+	if u.Scheme == "" {
+		return DSN{}, fmt.Errorf("missing scheme in url %q", url)
+	}
+
+	dsn := DSN{
+		Scheme:   u.Scheme,
+		Host:     u.Hostname(),
+		Database: u.Path,
+	}
+	if u.Host != "" {
+		dsn.Database = strings.TrimPrefix(u.Path, "/")
+	}
+	if port := u.Port(); port != "" {
+		if dsn.Port, err = strconv.Atoi(port); err != nil {
+			return DSN{}, fmt.Errorf("invalid port %q in url", port)
+		}
+	}
+	if u.User != nil {
+		dsn.User = u.User.Username()
+		dsn.Password, _ = u.User.Password()
+	}
+	if query := u.Query(); len(query) > 0 {
+		dsn.Params = make(map[string]string, len(query))
+		for key := range query {
+			dsn.Params[key] = query.Get(key)
+		}
+	}
+	return dsn, nil
+}
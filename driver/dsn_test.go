@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	var tests = []struct {
+		dsn DSN
+		url string
+	}{
+		{
+			DSN{Scheme: "postgres", Host: "localhost", Port: 5432, User: "postgres", Database: "template1", Params: map[string]string{"sslmode": "disable"}},
+			"postgres://postgres@localhost:5432/template1?sslmode=disable",
+		},
+		{
+			DSN{Scheme: "postgres", Host: "db", User: "migrate", Password: "p@ss/word?", Database: "app", Params: map[string]string{"x-init-sql": "SET search_path = app", "sslmode": "require"}},
+			"postgres://migrate:p%40ss%2Fword%3F@db/app?sslmode=require&x-init-sql=SET+search_path+%3D+app",
+		},
+		{
+			DSN{Scheme: "mysql", Host: "::1", Port: 3306, User: "root", Database: "test"},
+			"mysql://root@[::1]:3306/test",
+		},
+		{
+			DSN{Scheme: "sqlite3", Database: "/tmp/test.db"},
+			"sqlite3:///tmp/test.db",
+		},
+	}
+
+	for _, test := range tests {
+		url := test.dsn.String()
+		if url != test.url {
+			t.Errorf("Expected %v, got %v", test.url, url)
+		}
+		dsn, err := ParseDSN(url)
+		if err != nil {
+			t.Errorf("ParseDSN(%q) returned error %v", url, err)
+			continue
+		}
+		if !reflect.DeepEqual(dsn, test.dsn) {
+			t.Errorf("Expected %q to round-trip to %+v, got %+v", url, test.dsn, dsn)
+		}
+	}
+
+	// relative file names stay relative, and the url round-trips
+	relative := DSN{Scheme: "sqlite3", Database: "test.db"}
+	if url := relative.String(); url != "sqlite3://test.db" {
+		t.Errorf("Expected sqlite3://test.db, got %v", url)
+	} else if dsn, err := ParseDSN(url); err != nil || dsn.String() != url {
+		t.Errorf("Expected %q to round-trip, got %v (%v)", url, dsn, err)
+	}
+
+	for _, url := range []string{"localhost/db", "postgres://localhost:port/db"} {
+		if _, err := ParseDSN(url); err == nil {
+			t.Errorf("Expected ParseDSN(%q) to fail", url)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package duckdb implements the Driver interface for DuckDB.
+package duckdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+type Driver struct {
+	db *sql.DB
+}
+
+const tableName = "schema_migrations"
+
+// DuckDB Driver URL format:
+// duckdb://path/to/database.duckdb?options
+// duckdb://:memory:
+//
+// An empty path or :memory: opens an in-memory database, which is gone
+// once the driver is closed. Options are passed to
+// github.com/marcboeker/go-duckdb, e.g. access_mode=read_only.
+func (driver *Driver) Initialize(url string) error {
+	if !strings.HasPrefix(url, "duckdb://") {
+		return errors.New("invalid duckdb:// scheme")
+	}
+	dsn := strings.TrimPrefix(url, "duckdb://")
+	dsn = strings.TrimPrefix(dsn, ":memory:")
+
+	// connections of db share the database, in-memory ones included
+	db, err := sql.Open("duckdb", dsn)
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	driver.db = db
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) Close() error {
+	if err := driver.db.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) ensureVersionTableExists() error {
+	if _, err := driver.db.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version BIGINT PRIMARY KEY)"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs the statements of the file one by one in a transaction,
+// which also records the version. DuckDB runs DDL in transactions, so
+// a failed migration is rolled back completely.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	tx, err := driver.db.Begin()
+	if err != nil {
+		return
+	}
+	for _, stmt := range file.SplitStatements(f.Content) {
+		if _, err = tx.Exec(string(stmt)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, stmt)
+		}
+	}
+
+	if f.Direction == direction.Up {
+		_, err = tx.Exec("INSERT INTO "+tableName+" (version) VALUES (?)", int64(f.Version))
+	} else if f.Direction == direction.Down {
+		_, err = tx.Exec("DELETE FROM "+tableName+" WHERE version = ?", int64(f.Version))
+	}
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	_, err := driver.db.Exec("DELETE FROM "+tableName+" WHERE version = ?", int64(version))
+	return err
+}
+
+// TransactionalDDL returns true.
+// DuckDB runs DDL statements in transactions.
+func (driver *Driver) TransactionalDDL() bool {
+	return true
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	var version int64
+	err := driver.db.QueryRow("SELECT version FROM " + tableName + " ORDER BY version DESC LIMIT 1").Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return uint64(version), nil
+	}
+}
+
+func init() {
+	driver.RegisterDriver("duckdb", &Driver{})
+}
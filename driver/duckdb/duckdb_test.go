@@ -0,0 +1,107 @@
+package duckdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// TestMigrate runs some additional tests on Migrate()
+// Basic testing is already done in migrate/migrate_test.go
+func TestMigrate(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("duckdb://:memory:"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INTEGER PRIMARY KEY, msg VARCHAR);
+				INSERT INTO yolo VALUES (1, 'a;b');
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INTEGER);
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				);
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+	var count int
+	if err := d.db.QueryRow("SELECT count(*) FROM information_schema.tables WHERE table_name = 'yolo'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("Expected failed migration to be rolled back, got %v tables (%v)", count, err)
+	}
+}
+
+func TestFileBacked(t *testing.T) {
+	driverUrl := "duckdb://" + filepath.Join(t.TempDir(), "migratetest.duckdb")
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	f := file.File{Version: 1, Direction: direction.Up, Content: []byte("CREATE TABLE yolo (id INTEGER)")}
+	if err := d.Migrate(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the version survives reopening the database
+	d = &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+}
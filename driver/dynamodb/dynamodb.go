@@ -0,0 +1,276 @@
+// Package dynamodb implements the Driver interface for Amazon DynamoDB.
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url" // alias to allow `url string` func signature in Initialize
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+type Driver struct {
+	client *dynamodb.Client
+}
+
+const (
+	tableName = "schema_migrations"
+
+	// versionItemID is the id of the item holding the applied versions
+	versionItemID = "versions"
+)
+
+// pollInterval is the time between checks whether
+// a changed table became active
+var pollInterval = 2 * time.Second
+
+// operationsSchema is the JSON schema of migration files, an array of
+// API calls with their input in the JSON form of the AWS API reference
+var operationsSchema = []byte(`{
+	"type": "array",
+	"items": {
+		"type": "object",
+		"required": ["op", "input"],
+		"properties": {
+			"op": {"type": "string", "enum": ["CreateTable", "UpdateTable", "DeleteTable", "UpdateTimeToLive"]},
+			"input": {"type": "object"}
+		},
+		"additionalProperties": false
+	}
+}`)
+
+// DynamoDB Driver URL format:
+// dynamodb://region?endpoint=http://localhost:8000
+//
+// endpoint is optional, e.g. for DynamoDB local. Credentials are read
+// from the environment like the AWS CLI does.
+func (driver *Driver) Initialize(url string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "dynamodb" {
+		return errors.New("invalid dynamodb:// scheme")
+	}
+	if u.Host == "" {
+		return errors.New("dynamodb:// url requires a region")
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(u.Host))
+	if err != nil {
+		return err
+	}
+	endpoint := u.Query().Get("endpoint")
+	driver.client = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) Close() error {
+	return nil
+}
+
+func (driver *Driver) ensureVersionTableExists() error {
+	ctx := context.Background()
+	_, err := driver.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	_, err = driver.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return err
+	}
+	return driver.waitForTable(ctx, tableName, false)
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "json"
+}
+
+// JSONSchema returns the schema of migration files.
+func (driver *Driver) JSONSchema() []byte {
+	return operationsSchema
+}
+
+// operation is a call of the DynamoDB API
+type operation struct {
+	Op    string          `json:"op"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Migrate runs the operations of the file one by one and waits until
+// each changed table, including its global secondary indexes, is active
+// or deleted. DynamoDB doesn't support transactions for table changes,
+// so if an operation fails, the operations before stay applied and the
+// version isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	var operations []operation
+	if err = json.Unmarshal(f.Content, &operations); err != nil {
+		return fmt.Errorf("%s: %v", f.FileName, err)
+	}
+	ctx := context.Background()
+	for i, op := range operations {
+		if err = driver.run(ctx, op); err != nil {
+			return fmt.Errorf("%s: operation %d, %s: %v", f.FileName, i+1, op.Op, err)
+		}
+	}
+
+	return driver.recordVersion(ctx, f)
+}
+
+// recordVersion adds the version of f to the version item or
+// deletes it, depending on its direction
+func (driver *Driver) recordVersion(ctx context.Context, f file.File) (err error) {
+	// the versions are a number set, ADD and DELETE are idempotent
+	action := "ADD"
+	if f.Direction == direction.Down {
+		action = "DELETE"
+	}
+	_, err = driver.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(tableName),
+		Key:                      map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: versionItemID}},
+		UpdateExpression:         aws.String(action + " #versions :v"),
+		ExpressionAttributeNames: map[string]string{"#versions": "versions"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberNS{Value: []string{strconv.FormatUint(f.Version, 10)}},
+		},
+	})
+	return
+}
+
+// run calls the API of op and waits for the changed table
+func (driver *Driver) run(ctx context.Context, op operation) (err error) {
+	var table *string
+	switch op.Op {
+	case "CreateTable":
+		input := &dynamodb.CreateTableInput{}
+		if err = json.Unmarshal(op.Input, input); err == nil {
+			table = input.TableName
+			_, err = driver.client.CreateTable(ctx, input)
+		}
+	case "UpdateTable":
+		input := &dynamodb.UpdateTableInput{}
+		if err = json.Unmarshal(op.Input, input); err == nil {
+			table = input.TableName
+			_, err = driver.client.UpdateTable(ctx, input)
+		}
+	case "DeleteTable":
+		input := &dynamodb.DeleteTableInput{}
+		if err = json.Unmarshal(op.Input, input); err == nil {
+			table = input.TableName
+			_, err = driver.client.DeleteTable(ctx, input)
+		}
+	case "UpdateTimeToLive":
+		input := &dynamodb.UpdateTimeToLiveInput{}
+		if err = json.Unmarshal(op.Input, input); err == nil {
+			table = input.TableName
+			_, err = driver.client.UpdateTimeToLive(ctx, input)
+		}
+	default:
+		return errors.New("unsupported operation")
+	}
+	if err != nil {
+		return
+	}
+	return driver.waitForTable(ctx, aws.ToString(table), op.Op == "DeleteTable")
+}
+
+// waitForTable polls the table until it and all its global secondary
+// indexes are active, or until it is gone if deleted is true
+func (driver *Driver) waitForTable(ctx context.Context, table string, deleted bool) error {
+	for {
+		out, err := driver.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		var notFound *types.ResourceNotFoundException
+		switch {
+		case deleted && errors.As(err, &notFound):
+			return nil
+		case err != nil:
+			return err
+		case !deleted && tableActive(out.Table):
+			return nil
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// tableActive reports whether t and all its global secondary indexes are active
+func tableActive(t *types.TableDescription) bool {
+	if t == nil || t.TableStatus != types.TableStatusActive {
+		return false
+	}
+	for _, index := range t.GlobalSecondaryIndexes {
+		if index.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	return driver.recordVersion(context.Background(), file.File{Version: version, Direction: direction.Down})
+}
+
+// TransactionalDDL returns false.
+// DynamoDB applies table changes one by one.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	out, err := driver.client.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: versionItemID}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+	set, ok := out.Item["versions"].(*types.AttributeValueMemberNS)
+	if !ok {
+		return 0, nil
+	}
+	var max uint64
+	for _, v := range set.Value {
+		version, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if version > max {
+			max = version
+		}
+	}
+	return max, nil
+}
+
+func init() {
+	driver.RegisterDriver("dynamodb", &Driver{})
+}
@@ -0,0 +1,130 @@
+package dynamodb
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestOperationsSchema(t *testing.T) {
+	var tests = []struct {
+		content string
+		valid   bool
+	}{
+		{`[{"op": "CreateTable", "input": {"TableName": "yolo"}}]`, true},
+		{`[{"op": "PutItem", "input": {"TableName": "yolo"}}]`, false},
+		{`[{"op": "DeleteTable"}]`, false},
+		{`[{"op": "DeleteTable", "input": {}, "wait": true}]`, false},
+		{`{"op": "DeleteTable", "input": {}}`, false},
+	}
+	for _, test := range tests {
+		files := file.MigrationFiles{{Version: 1, UpFile: &file.File{FileName: "001_foo.up.json", Content: []byte(test.content)}}}
+		if err := files.ValidateJSON(operationsSchema); (err == nil) != test.valid {
+			t.Errorf("Expected %s to be valid %v, got %v", test.content, test.valid, err)
+		}
+	}
+}
+
+func TestTableActive(t *testing.T) {
+	active := []types.GlobalSecondaryIndexDescription{{IndexStatus: types.IndexStatusActive}}
+	creating := []types.GlobalSecondaryIndexDescription{{IndexStatus: types.IndexStatusActive}, {IndexStatus: "CREATING"}}
+	tests := []struct {
+		table  *types.TableDescription
+		expect bool
+	}{
+		{nil, false},
+		{&types.TableDescription{TableStatus: "CREATING"}, false},
+		{&types.TableDescription{TableStatus: types.TableStatusActive}, true},
+		{&types.TableDescription{TableStatus: types.TableStatusActive, GlobalSecondaryIndexes: active}, true},
+		{&types.TableDescription{TableStatus: types.TableStatusActive, GlobalSecondaryIndexes: creating}, false},
+	}
+	for i, tt := range tests {
+		if got := tableActive(tt.table); got != tt.expect {
+			t.Errorf("Expected %v for table %v, got %v", tt.expect, i, got)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires DYNAMODB_ENDPOINT of DynamoDB local.
+func TestMigrate(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set")
+	}
+	pollInterval = 0
+
+	d := &Driver{}
+	if err := d.Initialize("dynamodb://us-east-1?endpoint=" + endpoint); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.json",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`[
+				{"op": "CreateTable", "input": {
+					"TableName": "yolo",
+					"AttributeDefinitions": [{"AttributeName": "id", "AttributeType": "S"}, {"AttributeName": "msg", "AttributeType": "S"}],
+					"KeySchema": [{"AttributeName": "id", "KeyType": "HASH"}],
+					"BillingMode": "PAY_PER_REQUEST"
+				}},
+				{"op": "UpdateTable", "input": {
+					"TableName": "yolo",
+					"AttributeDefinitions": [{"AttributeName": "msg", "AttributeType": "S"}],
+					"GlobalSecondaryIndexUpdates": [{"Create": {
+						"IndexName": "yolo_msg",
+						"KeySchema": [{"AttributeName": "msg", "KeyType": "HASH"}],
+						"Projection": {"ProjectionType": "KEYS_ONLY"}
+					}}]
+				}}
+			]`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.json",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content:   []byte(`[{"op": "DeleteTable", "input": {"TableName": "yolo"}}]`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.json",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content:   []byte(`[{"op": "DeleteTable", "input": {"TableName": "does_not_exist"}}]`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil || !strings.Contains(err.Error(), "operation 1, DeleteTable") {
+		t.Errorf("Expected test case to fail, got %v", err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+}
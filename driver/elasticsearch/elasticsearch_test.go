@@ -0,0 +1,164 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestRequestBody(t *testing.T) {
+	for body, expect := range map[string]string{
+		``:                                  ``,
+		`{"a": 1}`:                          `{"a": 1}`,
+		`[{"index": {}}, {"a": 1}]`:         "{\"index\":{}}\n{\"a\":1}\n",
+		" [\n{\"delete\": {\"_id\": 1}}\n]": "{\"delete\":{\"_id\":1}}\n",
+	} {
+		got, err := requestBody(json.RawMessage(body))
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", body, err)
+		} else if string(got) != expect {
+			t.Errorf("Expected %q for %q, got %q", expect, body, got)
+		}
+	}
+}
+
+// server fakes the parts of the REST API used by the driver
+type server struct {
+	mu       sync.Mutex
+	index    bool
+	versions map[string]bool
+	paths    []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, password, ok := r.BasicAuth(); !ok || user != "elastic" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+
+	switch path := r.URL.Path; {
+	case path == "/":
+		fmt.Fprint(w, `{"version": {"number": "8.0.0"}}`)
+	case path == "/"+indexName && r.Method == "HEAD":
+		if !s.index {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case path == "/"+indexName && r.Method == "PUT":
+		s.index = true
+	case strings.HasPrefix(path, "/"+indexName+"/_doc/"):
+		version := strings.TrimPrefix(path, "/"+indexName+"/_doc/")
+		if r.Method == "DELETE" {
+			delete(s.versions, version)
+		} else {
+			s.versions[version] = true
+		}
+	case path == "/"+indexName+"/_search":
+		var max int
+		for v := range s.versions {
+			var version int
+			fmt.Sscan(v, &version)
+			if version > max {
+				max = version
+			}
+		}
+		hits := "[]"
+		if max > 0 {
+			hits = fmt.Sprintf(`[{"_source": {"version": %d}}]`, max)
+		}
+		fmt.Fprintf(w, `{"hits": {"hits": %s}}`, hits)
+	case path == "/logs" && r.Method == "PUT" && json.Valid(body):
+		s.paths = append(s.paths, r.Method+" "+path)
+	case path == "/_bulk" && r.Header.Get("Content-Type") == "application/x-ndjson":
+		s.paths = append(s.paths, r.Method+" "+path)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": "unexpected request"}`)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := &server{versions: make(map[string]bool)}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	d := &Driver{}
+	if err := d.Initialize("elasticsearch://elastic:secret@" + strings.TrimPrefix(ts.URL, "http://")); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if !s.index {
+		t.Fatal("Expected version index to be created")
+	}
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.json",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`[
+				{"method": "PUT", "path": "/logs", "body": {"mappings": {"properties": {"msg": {"type": "text"}}}}},
+				{"method": "POST", "path": "/_bulk", "body": [{"index": {"_index": "logs"}}, {"msg": "hello"}]}
+			]`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.json",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content:   []byte(`[]`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.json",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content:   []byte(`[{"method": "PUT", "path": "/this-will-cause-an-error"}]`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"PUT /logs", "POST /_bulk"}; strings.Join(s.paths, ",") != strings.Join(expect, ",") {
+		t.Errorf("Expected calls %v, got %v", expect, s.paths)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Errorf("Expected test case to fail with the response, got %v", err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+}
+
+func TestInitializeScheme(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("http://localhost:9200"); err == nil {
+		t.Error("Expected error for http:// url")
+	}
+}
@@ -0,0 +1,294 @@
+// Package generic implements the Driver interface for any database
+// with a database/sql driver, configured by a Dialect.
+package generic
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+type Driver struct {
+	db      *sql.DB
+	dialect Dialect
+
+	// sharedDB is true if db is owned by the caller, see WithDB
+	sharedDB bool
+
+	// tableExists is true once the version table is known to exist
+	tableExists bool
+}
+
+// Dialect describes how to migrate a database through its
+// database/sql driver.
+type Dialect struct {
+
+	// DriverName is the name the database/sql driver is
+	// registered with, e.g. "odbc".
+	DriverName string
+
+	// Placeholder returns the placeholder of the n-th parameter of
+	// a statement, counting from 1. It defaults to QuestionMark.
+	Placeholder func(n int) string
+
+	// CreateVersionTable is the statement creating the version table
+	// schema_migrations with a column version, which must hold uint64
+	// values. It is only run if the table doesn't exist yet, and
+	// defaults to DefaultCreateVersionTable.
+	CreateVersionTable string
+
+	// TransactionalDDL is true if the database runs DDL statements in
+	// transactions. Files then run in a transaction, which also records
+	// the version. Otherwise statements run one by one without one.
+	TransactionalDDL bool
+
+	// SplitStatements makes statements run one by one, split at ;
+	// except within quotes and comments, for database/sql drivers which
+	// don't accept multiple statements at once. Files always run
+	// statement by statement if TransactionalDDL is false.
+	SplitStatements bool
+}
+
+const tableName = "schema_migrations"
+
+// DefaultCreateVersionTable is the statement creating the
+// version table if a dialect doesn't set one
+const DefaultCreateVersionTable = "CREATE TABLE " + tableName + " (version bigint not null primary key)"
+
+// QuestionMark returns ? placeholders.
+func QuestionMark(n int) string {
+	return "?"
+}
+
+// Dollar returns $1, $2 ... placeholders.
+func Dollar(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Colon returns :1, :2 ... placeholders.
+func Colon(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+// AtP returns @p1, @p2 ... placeholders.
+func AtP(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+var dialectsMu sync.Mutex
+var dialects = make(map[string]Dialect)
+
+// Register registers a driver for urls with the given scheme, which
+// migrates databases through the database/sql driver of d. Urls are
+// passed to it without the scheme, e.g. scheme://dsn as dsn. Like
+// database/sql drivers, call Register from an init() function.
+func Register(scheme string, d Dialect) {
+	if d.DriverName == "" {
+		panic("generic: Register dialect without DriverName")
+	}
+	d = withDefaults(d)
+
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	if _, dup := dialects[scheme]; dup {
+		panic("generic: Register called twice for scheme " + scheme)
+	}
+	dialects[scheme] = d
+	driver.RegisterDriver(scheme, &Driver{})
+}
+
+// withDefaults returns d with the defaults of unset fields
+func withDefaults(d Dialect) Dialect {
+	if d.Placeholder == nil {
+		d.Placeholder = QuestionMark
+	}
+	if d.CreateVersionTable == "" {
+		d.CreateVersionTable = DefaultCreateVersionTable
+	}
+	return d
+}
+
+// getDialect returns the dialect registered for scheme
+func getDialect(scheme string) (Dialect, bool) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	d, ok := dialects[scheme]
+	return d, ok
+}
+
+// Generic Driver URL format:
+// scheme://dsn
+//
+// scheme is the one passed to Register, dsn is passed to the
+// database/sql driver of the dialect.
+func (driver *Driver) Initialize(url string) error {
+	parts := strings.SplitN(url, "://", 2)
+	if len(parts) != 2 {
+		return errors.New("invalid url, expected scheme://dsn")
+	}
+	// look the dialect up by scheme, since driver.NewInstance
+	// returns instances without one
+	dialect, ok := getDialect(parts[0])
+	if !ok {
+		return fmt.Errorf("no dialect registered for %s://", parts[0])
+	}
+	driver.dialect = dialect
+	driver.sharedDB = false
+	driver.tableExists = false
+
+	db, err := sql.Open(dialect.DriverName, parts[1])
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	driver.db = db
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WithDB returns a driver working on an existing *sql.DB with dialect
+// d, e.g. on the connection pool of an application. DriverName of d
+// isn't used. The version table is created on first use. Close doesn't
+// close db, its owner remains responsible.
+func WithDB(db *sql.DB, d Dialect) *Driver {
+	return &Driver{db: db, dialect: withDefaults(d), sharedDB: true}
+}
+
+func (driver *Driver) Close() error {
+	if driver.sharedDB {
+		return nil
+	}
+	if err := driver.db.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureVersionTableExists runs the statement of the dialect creating
+// the version table, unless querying the table succeeds. Not all
+// databases support CREATE TABLE IF NOT EXISTS.
+func (driver *Driver) ensureVersionTableExists() error {
+	if driver.tableExists {
+		return nil
+	}
+	rows, err := driver.db.Query("SELECT version FROM " + tableName + " WHERE 1 = 0")
+	if err == nil {
+		err = rows.Close()
+	} else {
+		_, err = driver.db.Exec(driver.dialect.CreateVersionTable)
+	}
+	if err != nil {
+		return err
+	}
+	driver.tableExists = true
+	return nil
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Migrate runs f in a transaction, which also records the version, if
+// the dialect has transactional DDL. Otherwise the statements of f run
+// one by one, and if a statement fails, the statements before stay
+// applied and the version isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+	if err = driver.ensureVersionTableExists(); err != nil {
+		return
+	}
+
+	if !driver.dialect.TransactionalDDL {
+		if err = driver.run(driver.db, f); err != nil {
+			return
+		}
+		return driver.recordVersion(driver.db, f)
+	}
+
+	tx, err := driver.db.Begin()
+	if err != nil {
+		return
+	}
+	if err = driver.run(tx, f); err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = driver.recordVersion(tx, f); err != nil {
+		tx.Rollback()
+		return
+	}
+	return tx.Commit()
+}
+
+// run runs the content of f, statement by statement if the
+// dialect splits statements or has no transactional DDL
+func (driver *Driver) run(e execer, f file.File) error {
+	if driver.dialect.TransactionalDDL && !driver.dialect.SplitStatements {
+		if _, err := e.Exec(string(f.Content)); err != nil {
+			return fmt.Errorf("%s: %v", f.FileName, err)
+		}
+		return nil
+	}
+	for _, stmt := range file.SplitStatements(f.Content) {
+		if _, err := e.Exec(string(stmt)); err != nil {
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, stmt)
+		}
+	}
+	return nil
+}
+
+// recordVersion inserts or deletes the version of f, depending on its direction
+func (driver *Driver) recordVersion(e execer, f file.File) (err error) {
+	placeholder := driver.dialect.Placeholder(1)
+	if f.Direction == direction.Up {
+		_, err = e.Exec("INSERT INTO "+tableName+" (version) VALUES ("+placeholder+")", int64(f.Version))
+	} else if f.Direction == direction.Down {
+		_, err = e.Exec("DELETE FROM "+tableName+" WHERE version = "+placeholder, int64(f.Version))
+	}
+	return
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return driver.recordVersion(driver.db, file.File{Version: version, Direction: direction.Down})
+}
+
+// TransactionalDDL returns the TransactionalDDL flag of the dialect.
+func (driver *Driver) TransactionalDDL() bool {
+	return driver.dialect.TransactionalDDL
+}
+
+// Version returns the greatest applied version. It uses max instead of
+// ORDER BY ... LIMIT, which not all databases support.
+func (driver *Driver) Version() (uint64, error) {
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := driver.db.QueryRow("SELECT max(version) FROM " + tableName).Scan(&version); err != nil {
+		return 0, err
+	}
+	return uint64(version.Int64), nil
+}
@@ -0,0 +1,151 @@
+package generic
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	Register("generic-tx", Dialect{DriverName: "sqlite3", TransactionalDDL: true})
+	Register("generic-notx", Dialect{
+		DriverName:         "sqlite3",
+		Placeholder:        Dollar,
+		CreateVersionTable: "CREATE TABLE " + tableName + " (version INTEGER PRIMARY KEY)",
+	})
+}
+
+func TestPlaceholders(t *testing.T) {
+	for _, tt := range []struct {
+		placeholder func(n int) string
+		expect      string
+	}{
+		{QuestionMark, "?"},
+		{Dollar, "$2"},
+		{Colon, ":2"},
+		{AtP, "@p2"},
+	} {
+		if got := tt.placeholder(2); got != tt.expect {
+			t.Errorf("Expected %v, got %v", tt.expect, got)
+		}
+	}
+}
+
+// TestMigrate migrates sqlite3 databases with a transactional
+// and a non-transactional dialect.
+func TestMigrate(t *testing.T) {
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INTEGER PRIMARY KEY, msg TEXT);
+				INSERT INTO yolo (msg) VALUES ('a;b');
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INTEGER);
+				INSERT INTO this_will_cause_an_error VALUES (1);
+			`),
+		},
+	}
+
+	for scheme, transactional := range map[string]bool{"generic-tx": true, "generic-notx": false} {
+		d, err := driver.NewInstance(scheme + "://" + filepath.Join(t.TempDir(), "migratetest.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		if got := d.(*Driver).TransactionalDDL(); got != transactional {
+			t.Errorf("%s: Expected TransactionalDDL %v, got %v", scheme, transactional, got)
+		}
+
+		if err := d.Migrate(files[0]); err != nil {
+			t.Fatal(err)
+		}
+		if version, err := d.Version(); err != nil || version != 1 {
+			t.Fatalf("%s: Expected version 1, got %v (%v)", scheme, version, err)
+		}
+
+		if err := d.Migrate(files[1]); err != nil {
+			t.Fatal(err)
+		}
+		if version, err := d.Version(); err != nil || version != 0 {
+			t.Fatalf("%s: Expected version 0, got %v (%v)", scheme, version, err)
+		}
+
+		if err := d.Migrate(files[2]); err == nil {
+			t.Errorf("%s: Expected test case to fail", scheme)
+		}
+		if version, err := d.Version(); err != nil || version != 0 {
+			t.Errorf("%s: Expected version 0, got %v (%v)", scheme, version, err)
+		}
+
+		// only the transactional dialect rolls back the first statement
+		var count int
+		if err := d.(*Driver).db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'yolo'").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if expect := map[bool]int{true: 0, false: 1}[transactional]; count != expect {
+			t.Errorf("%s: Expected %v yolo tables, got %v", scheme, expect, count)
+		}
+	}
+}
+
+func TestInitializeUnregistered(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("nodialect://foo"); err == nil {
+		t.Error("Expected error for scheme without dialect")
+	}
+}
+
+func TestWithDB(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migratetest.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := WithDB(db, Dialect{TransactionalDDL: true})
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+	f := file.File{Version: 1, Direction: direction.Up, Content: []byte("CREATE TABLE yolo (id INTEGER)")}
+	if err := d.Migrate(f); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Error("Expected shared DB to remain open:", err)
+	}
+}
@@ -0,0 +1,113 @@
+package hana
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires HANA_HOST, HANA_PORT, HANA_USER and HANA_PASSWORD.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("HANA_HOST")
+	if host == "" {
+		t.Skip("HANA_HOST not set")
+	}
+	port := os.Getenv("HANA_PORT")
+	driverUrl := "hana://" + os.Getenv("HANA_USER") + ":" + os.Getenv("HANA_PASSWORD") + "@" + host + ":" + port
+
+	// prepare clean database
+	connection, err := sql.Open("hdb", "hdb://"+os.Getenv("HANA_USER")+":"+os.Getenv("HANA_PASSWORD")+"@"+host+":"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	for _, table := range []string{"YOLO", tableName} {
+		var count int
+		if err := connection.QueryRow("SELECT COUNT(*) FROM SYS.TABLES WHERE SCHEMA_NAME = CURRENT_SCHEMA AND TABLE_NAME = ?", table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count > 0 {
+			if _, err := connection.Exec("DROP TABLE " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE COLUMN TABLE yolo (
+					id INTEGER NOT NULL PRIMARY KEY
+				);
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE COLUMN TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if _, err := d.Version(); err == nil {
+		t.Error("Expected version 2 to be dirty")
+	}
+	if dirty, version, err := d.Dirty(); err != nil || !dirty || version != 2 {
+		t.Fatalf("Expected dirty version 2, got dirty=%v version %v (%v)", dirty, version, err)
+	}
+
+	if err := d.ClearDirty(); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 2 {
+		t.Errorf("Expected clean version 2, got %v (%v)", version, err)
+	}
+}
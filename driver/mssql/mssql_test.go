@@ -0,0 +1,143 @@
+package mssql
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestSplitBatches(t *testing.T) {
+	content := "CREATE TABLE t (id int);\nGO\n\nCREATE PROCEDURE p AS SELECT 1\n  go ;\r\nGO\nSELECT 'GO'\n"
+	batches := splitBatches([]byte(content))
+	expect := []string{"CREATE TABLE t (id int);", "CREATE PROCEDURE p AS SELECT 1", "SELECT 'GO'"}
+	if len(batches) != len(expect) {
+		t.Fatalf("Expected %v batches, got %q", len(expect), batches)
+	}
+	for i, e := range expect {
+		if string(batches[i]) != e {
+			t.Errorf("Expected batch %v to be %q, got %q", i, e, batches[i])
+		}
+	}
+}
+
+func TestNonTransactional(t *testing.T) {
+	tests := []struct {
+		content string
+		expect  bool
+	}{
+		{"CREATE TABLE t (id int);", false},
+		{"CREATE DATABASE foo;", true},
+		{"  alter database foo SET READ_COMMITTED_SNAPSHOT ON;", true},
+		{"CREATE FULLTEXT CATALOG ft;", true},
+		{"SELECT 1;\nRECONFIGURE;", true},
+		{"CREATE TABLE backup (id int);", false},
+		{"-- CREATE DATABASE in a comment\nSELECT 1;", false},
+	}
+	for _, tt := range tests {
+		if got := nonTransactionalRegex.MatchString(tt.content); got != tt.expect {
+			t.Errorf("Expected %v for %q, got %v", tt.expect, tt.content, got)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires MSSQL_HOST and MSSQL_PASSWORD of the sa user.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("MSSQL_HOST")
+	if host == "" {
+		t.Skip("MSSQL_HOST not set")
+	}
+	driverUrl := "sqlserver://sa:" + os.Getenv("MSSQL_PASSWORD") + "@" + host + ":1433?database=master"
+
+	// prepare clean database
+	connection, err := sql.Open("sqlserver", driverUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	for _, table := range []string{"yolo", "yolo2", tableName} {
+		if _, err := connection.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := connection.Exec("DROP PROCEDURE IF EXISTS yolo_count"); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id int identity primary key);
+				GO
+				CREATE PROCEDURE yolo_count AS SELECT count(*) FROM yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP PROCEDURE yolo_count;
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo2 (id int);
+				GO
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+	// the first batch was rolled back with the failed one
+	if _, err := connection.Exec("SELECT * FROM yolo2"); err == nil {
+		t.Error("Expected table yolo2 to be rolled back")
+	}
+}
@@ -1,6 +1,9 @@
 package driver
 
 import (
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"reflect"
 	"sort"
 	"sync"
 )
@@ -42,3 +45,28 @@ func Drivers() []string {
 	sort.Strings(list)
 	return list
 }
+
+var dbDrivers = make(map[reflect.Type]func(db *sql.DB) Driver)
+
+// Registers a constructor for drivers working on an existing *sql.DB.
+// sqlDriver is an instance of the database/sql driver the constructor
+// accepts connections of. Drivers should call this from an init() function.
+func RegisterDBDriver(sqlDriver sqldriver.Driver, fn func(db *sql.DB) Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if sqlDriver == nil || fn == nil {
+		panic("driver: RegisterDBDriver driver is nil")
+	}
+	t := reflect.TypeOf(sqlDriver)
+	if _, dup := dbDrivers[t]; dup {
+		panic("driver: RegisterDBDriver called twice for " + t.String())
+	}
+	dbDrivers[t] = fn
+}
+
+// Retrieves the constructor for the database/sql driver of db
+func getDBDriver(db *sql.DB) func(db *sql.DB) Driver {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	return dbDrivers[reflect.TypeOf(db.Driver())]
+}
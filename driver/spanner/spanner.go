@@ -0,0 +1,202 @@
+// Package spanner implements the Driver interface for Google Cloud Spanner.
+package spanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	"google.golang.org/api/iterator"
+)
+
+type Driver struct {
+	client *spanner.Client
+	admin  *database.DatabaseAdminClient
+
+	// database is the database path, projects/p/instances/i/databases/d
+	database string
+}
+
+const tableName = "schema_migrations"
+
+// Spanner Driver URL format:
+// spanner://projects/project/instances/instance/databases/database
+//
+// Credentials are read from the environment, see
+// https://cloud.google.com/docs/authentication/application-default-credentials
+func (driver *Driver) Initialize(url string) error {
+	if !strings.HasPrefix(url, "spanner://") {
+		return errors.New("invalid spanner:// scheme")
+	}
+	driver.database = strings.TrimPrefix(url, "spanner://")
+	if !databaseRegex.MatchString(driver.database) {
+		return errors.New("spanner:// url requires projects/project/instances/instance/databases/database")
+	}
+
+	ctx := context.Background()
+	client, err := spanner.NewClient(ctx, driver.database)
+	if err != nil {
+		return err
+	}
+	admin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	driver.client = client
+	driver.admin = admin
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// databaseRegex matches database paths
+var databaseRegex = regexp.MustCompile(`^projects/[^/]+/instances/[^/]+/databases/[^/]+$`)
+
+func (driver *Driver) Close() error {
+	driver.client.Close()
+	return driver.admin.Close()
+}
+
+func (driver *Driver) ensureVersionTableExists() error {
+	return driver.updateDDL(context.Background(), []string{"CREATE TABLE IF NOT EXISTS " + tableName + " (version INT64 NOT NULL) PRIMARY KEY (version)"})
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs the statements of the file in order. Spanner doesn't
+// accept DDL on data connections, so consecutive DDL statements are
+// submitted as a single batch through the admin API, and waited for.
+// Consecutive DML statements run as a batch in a read-write
+// transaction. If a statement fails, the batches and statements before
+// stay applied and the version isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	ctx := context.Background()
+	for _, batch := range splitBatches(file.SplitStatements(f.Content)) {
+		if batch.ddl {
+			err = driver.updateDDL(ctx, batch.statements)
+		} else {
+			err = driver.updateDML(ctx, batch.statements)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, strings.Join(batch.statements, ";\n"))
+		}
+	}
+
+	var m *spanner.Mutation
+	if f.Direction == direction.Up {
+		m = spanner.InsertOrUpdate(tableName, []string{"version"}, []interface{}{int64(f.Version)})
+	} else if f.Direction == direction.Down {
+		m = spanner.Delete(tableName, spanner.Key{int64(f.Version)})
+	}
+	if m != nil {
+		_, err = driver.client.Apply(ctx, []*spanner.Mutation{m})
+	}
+	return
+}
+
+// batch is a sequence of either DDL or DML statements
+type batch struct {
+	ddl        bool
+	statements []string
+}
+
+// ddlRegex matches statements Spanner only accepts through the admin API,
+// ignoring leading whitespace and comments
+var ddlRegex = regexp.MustCompile(`(?i)^(\s|--[^\n]*(\n|$)|/\*(?s:.*?)\*/)*(CREATE|ALTER|DROP|GRANT|REVOKE|RENAME|ANALYZE)\b`)
+
+// commentRegex matches statements consisting of comments only,
+// which Spanner rejects
+var commentRegex = regexp.MustCompile(`^(?s:\s*(--[^\n]*|/\*.*?\*/))*\s*$`)
+
+// splitBatches groups consecutive DDL and DML statements
+func splitBatches(stmts [][]byte) []batch {
+	batches := make([]batch, 0)
+	for _, stmt := range stmts {
+		if commentRegex.Match(stmt) {
+			continue
+		}
+		ddl := ddlRegex.Match(stmt)
+		if n := len(batches); n > 0 && batches[n-1].ddl == ddl {
+			batches[n-1].statements = append(batches[n-1].statements, string(stmt))
+			continue
+		}
+		batches = append(batches, batch{ddl: ddl, statements: []string{string(stmt)}})
+	}
+	return batches
+}
+
+// updateDDL submits DDL statements and waits until they're applied
+func (driver *Driver) updateDDL(ctx context.Context, stmts []string) error {
+	op, err := driver.admin.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   driver.database,
+		Statements: stmts,
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// updateDML runs DML statements in a read-write transaction
+func (driver *Driver) updateDML(ctx context.Context, stmts []string) error {
+	_, err := driver.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		statements := make([]spanner.Statement, 0, len(stmts))
+		for _, stmt := range stmts {
+			statements = append(statements, spanner.NewStatement(stmt))
+		}
+		_, err := txn.BatchUpdate(ctx, statements)
+		return err
+	})
+	return err
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	_, err := driver.client.Apply(context.Background(), []*spanner.Mutation{spanner.Delete(tableName, spanner.Key{int64(version)})})
+	return err
+}
+
+// TransactionalDDL returns false.
+// Spanner applies DDL statements outside of transactions.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	iter := driver.client.Single().Query(context.Background(), spanner.NewStatement("SELECT version FROM "+tableName+" ORDER BY version DESC LIMIT 1"))
+	defer iter.Stop()
+
+	row, err := iter.Next()
+	switch {
+	case err == iterator.Done:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	var version int64
+	if err := row.Columns(&version); err != nil {
+		return 0, err
+	}
+	return uint64(version), nil
+}
+
+func init() {
+	driver.RegisterDriver("spanner", &Driver{})
+}
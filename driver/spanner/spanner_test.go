@@ -0,0 +1,123 @@
+package spanner
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestSplitBatches(t *testing.T) {
+	stmts := file.SplitStatements([]byte(`
+		CREATE TABLE yolo (id INT64 NOT NULL) PRIMARY KEY (id);
+		-- index for lookups
+		create index yolo_id on yolo (id);
+		INSERT INTO yolo (id) VALUES (1);
+		/* renumber */ UPDATE yolo SET id = 2 WHERE id = 1;
+		-- add msg
+		ALTER TABLE yolo ADD COLUMN msg STRING(MAX);
+		-- done
+	`))
+	batches := splitBatches(stmts)
+	expect := []struct {
+		ddl bool
+		n   int
+	}{{true, 2}, {false, 2}, {true, 1}}
+	if len(batches) != len(expect) {
+		t.Fatalf("Expected %v batches, got %+v", len(expect), batches)
+	}
+	for i, e := range expect {
+		if batches[i].ddl != e.ddl || len(batches[i].statements) != e.n {
+			t.Errorf("Expected batch %v to have %v statements with ddl %v, got %+v", i, e.n, e.ddl, batches[i])
+		}
+	}
+}
+
+func TestInitializeURL(t *testing.T) {
+	for _, url := range []string{
+		"postgres://projects/p/instances/i/databases/d",
+		"spanner://projects/p/instances/i",
+		"spanner://projects/p/instances/i/databases/d/tables",
+	} {
+		if err := (&Driver{}).Initialize(url); err == nil {
+			t.Errorf("Expected %v to be invalid", url)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires SPANNER_EMULATOR_HOST and SPANNER_DATABASE,
+// projects/p/instances/i/databases/d, of an existing database.
+func TestMigrate(t *testing.T) {
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("SPANNER_EMULATOR_HOST not set")
+	}
+	driverUrl := "spanner://" + os.Getenv("SPANNER_DATABASE")
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id INT64 NOT NULL, msg STRING(MAX)) PRIMARY KEY (id);
+				CREATE INDEX yolo_msg ON yolo (msg);
+				INSERT INTO yolo (id, msg) VALUES (1, 'a;b');
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DELETE FROM yolo WHERE true;
+				DROP INDEX yolo_msg;
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+}
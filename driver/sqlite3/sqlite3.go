@@ -2,6 +2,7 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -48,7 +49,18 @@ func (driver *Driver) Close() error {
 }
 
 func (driver *Driver) ensureVersionTableExists() error {
-	if _, err := driver.db.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version INTEGER PRIMARY KEY AUTOINCREMENT);"); err != nil {
+	return createVersionTable(driver.db)
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// createVersionTable creates the version table unless it exists.
+// SQLite stores INTEGER as 64 bit, so encoded semantic versions fit.
+func createVersionTable(e execer) error {
+	if _, err := e.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version INTEGER PRIMARY KEY AUTOINCREMENT);"); err != nil {
 		return err
 	}
 	return nil
@@ -58,59 +70,192 @@ func (driver *Driver) FilenameExtension() string {
 	return "sql"
 }
 
-func (driver *Driver) Migrate(f file.File, pipe chan interface{}) {
-	defer close(pipe)
-	pipe <- f
-
+func (driver *Driver) Migrate(f file.File) (err error) {
 	tx, err := driver.db.Begin()
 	if err != nil {
-		pipe <- err
 		return
 	}
 
-	if f.Direction == direction.Up {
-		if _, err := tx.Exec("INSERT INTO "+tableName+" (version) VALUES (?)", f.Version); err != nil {
-			pipe <- err
-			if err := tx.Rollback(); err != nil {
-				pipe <- err
-			}
-			return
-		}
-	} else if f.Direction == direction.Down {
-		if _, err := tx.Exec("DELETE FROM "+tableName+" WHERE version=?", f.Version); err != nil {
-			pipe <- err
-			if err := tx.Rollback(); err != nil {
-				pipe <- err
-			}
-			return
-		}
+	if err = recordVersion(tx, f); err != nil {
+		tx.Rollback()
+		return
 	}
 
-	if err := f.ReadContent(); err != nil {
-		pipe <- err
+	if err = f.ReadContent(); err != nil {
+		tx.Rollback()
 		return
 	}
 
-	if _, err := tx.Exec(string(f.Content)); err != nil {
-		sqliteErr, isErr := err.(sqlite3.Error)
+	if _, err = tx.Exec(string(f.Content)); err != nil {
+		err = formatError(err)
+		tx.Rollback()
+		return
+	}
 
-		if isErr {
-			// The sqlite3 library only provides error codes, not position information. Output what we do know
-			pipe <- errors.New(fmt.Sprintf("SQLite Error (%s); Extended (%s)\nError: %s", sqliteErr.Code.Error(), sqliteErr.ExtendedCode.Error(), sqliteErr.Error()))
-		} else {
-			pipe <- errors.New(fmt.Sprintf("An error occurred: %s", err.Error()))
-		}
+	err = tx.Commit()
+	return
+}
 
-		if err := tx.Rollback(); err != nil {
-			pipe <- err
-		}
+// Refresh runs the content of f in a transaction
+// without recording its version.
+func (driver *Driver) Refresh(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		pipe <- err
+	tx, err := driver.db.Begin()
+	if err != nil {
+		return
+	}
+	if _, err = tx.Exec(string(f.Content)); err != nil {
+		err = formatError(err)
+		tx.Rollback()
 		return
 	}
+	err = tx.Commit()
+	return
+}
+
+// formatError adds the error codes to errors of the sqlite3 library
+func formatError(err error) error {
+	sqliteErr, isErr := err.(sqlite3.Error)
+
+	if isErr {
+		// The sqlite3 library only provides error codes, not position information. Output what we do know
+		return errors.New(fmt.Sprintf("SQLite Error (%s); Extended (%s)\nError: %s", sqliteErr.Code.Error(), sqliteErr.ExtendedCode.Error(), sqliteErr.Error()))
+	}
+	return errors.New(fmt.Sprintf("An error occurred: %s", err.Error()))
+}
+
+// MigrateGo runs a migration written in Go in a transaction,
+// which also records the version.
+func (driver *Driver) MigrateGo(f file.File) error {
+	tx, err := driver.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := recordVersion(tx, f); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := f.GoFunc(context.Background(), tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %v", f.FileName, err)
+	}
+	return tx.Commit()
+}
+
+// recordVersion inserts or deletes the version of f
+// depending on its direction.
+func recordVersion(tx *sql.Tx, f file.File) (err error) {
+	if f.Direction == direction.Up {
+		_, err = tx.Exec("INSERT INTO "+tableName+" (version) VALUES (?)", f.Version)
+	} else if f.Direction == direction.Down {
+		_, err = tx.Exec("DELETE FROM "+tableName+" WHERE version=?", f.Version)
+	}
+	return
+}
+
+// MigrateInTx runs f in tx, a transaction owned by the caller. The
+// version is recorded in tx as well, so all files migrated in tx are
+// committed or rolled back together by the caller.
+func MigrateInTx(tx *sql.Tx, f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if err := createVersionTable(tx); err != nil {
+		return err
+	}
+	if err := recordVersion(tx, f); err != nil {
+		return err
+	}
+	if f.GoFunc != nil {
+		if err := f.GoFunc(context.Background(), tx); err != nil {
+			return fmt.Errorf("%s: %v", f.FileName, err)
+		}
+		return nil
+	}
+	if _, err := tx.Exec(string(f.Content)); err != nil {
+		return formatError(err)
+	}
+	return nil
+}
+
+// TxDriver migrates files in a transaction owned by the caller,
+// see WithTx.
+type TxDriver struct {
+	tx *sql.Tx
+}
+
+// WithTx returns a driver running all migrations in tx with
+// MigrateInTx, e.g. for test fixtures rolled back afterwards.
+// Committing or rolling back tx is up to the caller, Close doesn't.
+func WithTx(tx *sql.Tx) *TxDriver {
+	return &TxDriver{tx: tx}
+}
+
+// Initialize returns an error, TxDriver is created by WithTx.
+func (driver *TxDriver) Initialize(url string) error {
+	return errors.New("sqlite3: a TxDriver can't be initialized with a url, use WithTx")
+}
+
+func (driver *TxDriver) Close() error {
+	return nil
+}
+
+func (driver *TxDriver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs f in the transaction, see MigrateInTx.
+func (driver *TxDriver) Migrate(f file.File) error {
+	return MigrateInTx(driver.tx, f)
+}
+
+// MigrateGo runs a migration written in Go in the transaction.
+func (driver *TxDriver) MigrateGo(f file.File) error {
+	return MigrateInTx(driver.tx, f)
+}
+
+// TransactionalDDL returns true.
+// All files run in the caller's transaction.
+func (driver *TxDriver) TransactionalDDL() bool {
+	return true
+}
+
+func (driver *TxDriver) Version() (uint64, error) {
+	if err := createVersionTable(driver.tx); err != nil {
+		return 0, err
+	}
+	var version uint64
+	err := driver.tx.QueryRow("SELECT version FROM " + tableName + " ORDER BY version DESC LIMIT 1").Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return version, nil
+	}
+}
+
+// ForceDown removes all versions greater than version
+// without running any migrations.
+func (driver *Driver) ForceDown(version uint64) error {
+	_, err := driver.db.Exec("DELETE FROM "+tableName+" WHERE version > ?", version)
+	return err
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	_, err := driver.db.Exec("DELETE FROM "+tableName+" WHERE version = ?", version)
+	return err
+}
+
+// TransactionalDDL returns true.
+// SQLite runs DDL statements in transactions.
+func (driver *Driver) TransactionalDDL() bool {
+	return true
 }
 
 func (driver *Driver) Version() (uint64, error) {
@@ -1,12 +1,15 @@
 package sqlite3
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/chr4/migrate/file"
 	"github.com/chr4/migrate/migrate/direction"
-	pipep "github.com/chr4/migrate/pipe"
 )
 
 // TestMigrate runs some additional tests on Migrate()
@@ -68,24 +71,15 @@ func TestMigrate(t *testing.T) {
 		},
 	}
 
-	pipe := pipep.New()
-	go d.Migrate(files[0], pipe)
-	errs := pipep.ReadErrors(pipe)
-	if len(errs) > 0 {
-		t.Fatal(errs)
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
 	}
 
-	pipe = pipep.New()
-	go d.Migrate(files[1], pipe)
-	errs = pipep.ReadErrors(pipe)
-	if len(errs) > 0 {
-		t.Fatal(errs)
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
 	}
 
-	pipe = pipep.New()
-	go d.Migrate(files[2], pipe)
-	errs = pipep.ReadErrors(pipe)
-	if len(errs) == 0 {
+	if err := d.Migrate(files[2]); err == nil {
 		t.Error("Expected test case to fail")
 	}
 
@@ -93,3 +87,163 @@ func TestMigrate(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestForceDown(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("sqlite3://:memory:"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	for v := uint64(1); v <= 5; v++ {
+		f := file.File{Version: v, Direction: direction.Up, Content: []byte("SELECT 1")}
+		if err := d.Migrate(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.ForceDown(3); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 3 {
+		t.Errorf("Expected version 3, got %v (%v)", version, err)
+	}
+	var count int
+	if err := d.db.QueryRow("SELECT count(*) FROM " + tableName).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 versions, got %v", count)
+	}
+}
+
+func TestSemanticVersion(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("sqlite3://:memory:"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	version, err := file.ParseSemver("999999.999999.999999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := file.File{Version: version, Direction: direction.Up, Content: []byte("SELECT 1")}
+	if err := d.Migrate(f); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.Version(); err != nil || v != version {
+		t.Errorf("Expected version %v, got %v (%v)", version, v, err)
+	}
+}
+
+func TestMigrateGo(t *testing.T) {
+	d := &Driver{}
+	if err := d.Initialize("sqlite3://:memory:"); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.db.Exec("CREATE TABLE yolo (id INTEGER PRIMARY KEY, secret TEXT); INSERT INTO yolo (secret) VALUES ('a'), ('b');"); err != nil {
+		t.Fatal(err)
+	}
+
+	// updates all rows, then fails if fail is set
+	reencrypt := func(fail bool) file.GoMigrationFunc {
+		return func(ctx context.Context, tx *sql.Tx) error {
+			rows, err := tx.QueryContext(ctx, "SELECT id, secret FROM yolo")
+			if err != nil {
+				return err
+			}
+			secrets := make(map[int]string)
+			for rows.Next() {
+				var id int
+				var secret string
+				if err := rows.Scan(&id, &secret); err != nil {
+					rows.Close()
+					return err
+				}
+				secrets[id] = secret
+			}
+			rows.Close()
+			for id, secret := range secrets {
+				if _, err := tx.ExecContext(ctx, "UPDATE yolo SET secret=? WHERE id=?", strings.ToUpper(secret), id); err != nil {
+					return err
+				}
+			}
+			if fail {
+				return errors.New("key unavailable")
+			}
+			return nil
+		}
+	}
+
+	f := file.File{FileName: "002_reencrypt.up.go", Version: 2, Direction: direction.Up, GoFunc: reencrypt(true)}
+	if err := d.MigrateGo(f); err == nil || !strings.Contains(err.Error(), "key unavailable") {
+		t.Errorf("Expected error of the Go migration, got %v", err)
+	}
+	var secrets string
+	if err := d.db.QueryRow("SELECT group_concat(secret, '') FROM yolo").Scan(&secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets != "ab" {
+		t.Errorf("Expected failed Go migration to be rolled back, got %q", secrets)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	f.GoFunc = reencrypt(false)
+	if err := d.MigrateGo(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.QueryRow("SELECT group_concat(secret, '') FROM yolo").Scan(&secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets != "AB" {
+		t.Errorf("Expected rows to be updated, got %q", secrets)
+	}
+	if version, err := d.Version(); err != nil || version != 2 {
+		t.Errorf("Expected version 2, got %v (%v)", version, err)
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	connection, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "fixture.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+
+	files := []file.File{
+		{FileName: "001_one.up.sql", Version: 1, Name: "one", Direction: direction.Up, Content: []byte("CREATE TABLE saga_one (id INTEGER);")},
+		{FileName: "002_two.up.sql", Version: 2, Name: "two", Direction: direction.Up, Content: []byte("CREATE TABLE saga_two (id INTEGER);")},
+	}
+	tx, err := connection.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := WithTx(tx)
+	for _, f := range files {
+		if err := d.Migrate(f); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+	}
+	if version, err := d.Version(); err != nil || version != 2 {
+		t.Errorf("Expected version 2 within the transaction, got %v (%v)", version, err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := connection.QueryRow("SELECT count(*) FROM sqlite_master WHERE name IN ('saga_one', 'saga_two', '" + tableName + "')").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected nothing to persist after rolling back the transaction, got %v tables", count)
+	}
+}
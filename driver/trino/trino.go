@@ -0,0 +1,141 @@
+// Package trino implements the Driver interface.
+package trino
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	neturl "net/url" // alias to allow `url string` func signature in Initialize
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	_ "github.com/trinodb/trino-go-client/trino"
+)
+
+type Driver struct {
+	db *sql.DB
+
+	// table is the catalog and schema qualified version table
+	table string
+
+	// statementFunc is called after each statement, see OnStatement
+	statementFunc func(f file.File, index int, d time.Duration)
+}
+
+const tableName = "schema_migrations"
+
+// Trino Driver URL format:
+// trino://user@host:port?catalog=memory&schema=default
+//
+// The version table is created in the given catalog and schema,
+// which must support writes. All other options are passed to
+// github.com/trinodb/trino-go-client.
+func (driver *Driver) Initialize(url string) error {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return err
+	}
+	catalog := u.Query().Get("catalog")
+	schema := u.Query().Get("schema")
+	if catalog == "" || schema == "" {
+		return errors.New("trino:// url requires catalog and schema")
+	}
+	u.Scheme = "http"
+
+	db, err := sql.Open("trino", u.String())
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return err
+	}
+	driver.db = db
+	driver.table = catalog + "." + schema + "." + tableName
+
+	if err := driver.ensureVersionTableExists(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) Close() error {
+	if err := driver.db.Close(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureVersionTableExists creates the version table. Many connectors
+// can't delete rows, so the table is append-only: every migration adds
+// a row and the most recent row of a version tells if it is applied.
+func (driver *Driver) ensureVersionTableExists() error {
+	if _, err := driver.db.Exec("CREATE TABLE IF NOT EXISTS " + driver.table + " (version bigint, applied boolean, seq bigint)"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (driver *Driver) FilenameExtension() string {
+	return "sql"
+}
+
+// Migrate runs the statements of the file one by one, since Trino
+// doesn't support multi-statement transactions. If a statement
+// fails, the statements before stay applied and the version
+// isn't recorded.
+func (driver *Driver) Migrate(f file.File) (err error) {
+	if err = f.ReadContent(); err != nil {
+		return
+	}
+
+	for i, stmt := range file.SplitStatements(f.Content) {
+		start := time.Now()
+		if _, err = driver.db.Exec(string(stmt)); err != nil {
+			return fmt.Errorf("%s: %v\n\n%s", f.FileName, err, stmt)
+		}
+		if driver.statementFunc != nil {
+			driver.statementFunc(f, i, time.Since(start))
+		}
+	}
+
+	// the client doesn't support query parameters for all statements
+	_, err = driver.db.Exec(fmt.Sprintf("INSERT INTO %s (version, applied, seq) VALUES (%d, %t, %d)", driver.table, f.Version, f.Direction == direction.Up, time.Now().UnixNano()))
+	return
+}
+
+// OnStatement sets a callback which is called after each
+// statement of a migration.
+func (driver *Driver) OnStatement(fn func(f file.File, index int, d time.Duration)) {
+	driver.statementFunc = fn
+}
+
+// RemoveVersion removes version without running a migration.
+func (driver *Driver) RemoveVersion(version uint64) error {
+	_, err := driver.db.Exec(fmt.Sprintf("INSERT INTO %s (version, applied, seq) VALUES (%d, false, %d)", driver.table, version, time.Now().UnixNano()))
+	return err
+}
+
+// TransactionalDDL returns false.
+// Trino runs each statement on its own.
+func (driver *Driver) TransactionalDDL() bool {
+	return false
+}
+
+func (driver *Driver) Version() (uint64, error) {
+	var version sql.NullInt64
+	err := driver.db.QueryRow("SELECT max(version) FROM (SELECT version, max_by(applied, seq) AS applied FROM " + driver.table + " GROUP BY version) WHERE applied").Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return uint64(version.Int64), nil
+	}
+}
+
+func init() {
+	driver.RegisterDriver("trino", &Driver{})
+}
@@ -0,0 +1,105 @@
+package trino
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires TRINO_HOST and TRINO_PORT of a server with a memory catalog.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("TRINO_HOST")
+	if host == "" {
+		t.Skip("TRINO_HOST not set")
+	}
+	port := os.Getenv("TRINO_PORT")
+	driverUrl := "trino://test@" + host + ":" + port + "?catalog=memory&schema=default"
+
+	// prepare clean database
+	connection, err := sql.Open("trino", "http://test@"+host+":"+port+"?catalog=memory&schema=default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	for _, table := range []string{"yolo", "yolo2", tableName} {
+		if _, err := connection.Exec("DROP TABLE IF EXISTS memory.default." + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id bigint);
+				CREATE TABLE yolo2 (id bigint);
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+				DROP TABLE yolo2;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				)
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	// up again after down
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+}
@@ -0,0 +1,141 @@
+package yugabyte
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+	"github.com/lib/pq"
+)
+
+func TestSplitBatches(t *testing.T) {
+	stmts := file.SplitStatements([]byte(`
+		CREATE TABLE a (id int primary key);
+		INSERT INTO a VALUES (1);
+		UPDATE a SET id = 2;
+		-- lookups by id
+		CREATE INDEX a_id ON a (id);
+		/* msg */ ALTER TABLE a ADD COLUMN msg text;
+		DELETE FROM a;
+	`))
+	expect := [][]string{
+		{"CREATE TABLE a (id int primary key)"},
+		{"INSERT INTO a VALUES (1)", "UPDATE a SET id = 2"},
+		{"-- lookups by id\n\t\tCREATE INDEX a_id ON a (id)"},
+		{"/* msg */ ALTER TABLE a ADD COLUMN msg text"},
+		{"DELETE FROM a"},
+	}
+	if got := splitBatches(stmts); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Expected %q, got %q", expect, got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err    error
+		expect bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{fmt.Errorf("wrapped: %w", &pq.Error{Code: "40001"}), true},
+		{&pq.Error{Code: "42P01"}, false},
+		{errors.New("40001"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.expect {
+			t.Errorf("Expected %v for %v, got %v", tt.expect, tt.err, got)
+		}
+	}
+}
+
+// TestMigrate runs some additional tests on Migrate().
+// It requires YUGABYTE_HOST of a node, whose yugabyte database
+// the tests may empty.
+func TestMigrate(t *testing.T) {
+	host := os.Getenv("YUGABYTE_HOST")
+	if host == "" {
+		t.Skip("YUGABYTE_HOST not set")
+	}
+	driverUrl := "yugabyte://yugabyte@" + host + "/yugabyte?sslmode=disable"
+
+	d := &Driver{}
+	if err := d.Initialize(driverUrl); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// prepare clean database
+	for _, stmt := range []string{"DROP TABLE IF EXISTS yolo", "DROP TABLE IF EXISTS " + lockTableName, "DELETE FROM " + tableName} {
+		if _, err := d.db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Unlock()
+
+	files := []file.File{
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.up.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE yolo (id int primary key, msg text);
+				INSERT INTO yolo VALUES (1, 'a;b');
+				INSERT INTO yolo VALUES (2, 'c');
+				CREATE INDEX yolo_msg ON yolo (msg);
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "001_foobar.down.sql",
+			Version:   1,
+			Name:      "foobar",
+			Direction: direction.Down,
+			Content: []byte(`
+				DROP TABLE yolo;
+			`),
+		},
+		{
+			Path:      "/foobar",
+			FileName:  "002_foobar.up.sql",
+			Version:   2,
+			Name:      "foobar",
+			Direction: direction.Up,
+			Content: []byte(`
+				CREATE TABLE error (
+					id THIS WILL CAUSE AN ERROR
+				);
+			`),
+		},
+	}
+
+	if err := d.Migrate(files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 1 {
+		t.Fatalf("Expected version 1, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[1]); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %v (%v)", version, err)
+	}
+
+	if err := d.Migrate(files[2]); err == nil {
+		t.Error("Expected test case to fail")
+	}
+	if version, err := d.Version(); err != nil || version != 0 {
+		t.Errorf("Expected version 0, got %v (%v)", version, err)
+	}
+}
@@ -3,29 +3,168 @@ package file
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/chr4/migrate/migrate/direction"
 	"go/token"
+	"io/fs"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var filenameRegex = `^([0-9]+)_(.*)\.(up|down)\.%s$`
 
+// bareFilenameRegex additionally matches filenames without up|down
+var bareFilenameRegex = `^([0-9]+)_(.*?)(?:\.(up|down))?\.%s$`
+
+// treatBareAsUp is an internal variable that holds the state
+// of bare filename parsing
+var treatBareAsUp = false
+
+// TreatBareAsUp makes filenames without up|down, like 003_foo.sql,
+// parse as up migrations without a down migration. It applies to
+// regular expressions built by FilenameRegex afterwards.
+func TreatBareAsUp(enable bool) {
+	treatBareAsUp = enable
+}
+
+// semanticVersions is an internal variable that holds the state
+// of semantic version parsing
+var semanticVersions = false
+
+// SemanticVersions makes filenames carry semantic versions like
+// 1.10.0_foo.up.sql instead of integers. They are sorted by semantic
+// version precedence, see ParseSemver. It applies to regular expressions
+// built by FilenameRegex afterwards.
+func SemanticVersions(enable bool) {
+	semanticVersions = enable
+}
+
+// UsesSemanticVersions reports whether SemanticVersions is enabled.
+func UsesSemanticVersions() bool {
+	return semanticVersions
+}
+
+// caseInsensitiveMatching is an internal variable that holds the
+// state of case-insensitive filename matching
+var caseInsensitiveMatching = false
+
+// CaseInsensitiveMatching makes filenames like 001_foo.UP.SQL or
+// 001_foo.up.Sql match, ignoring the case of direction and extension.
+// Versions are still parsed strictly. It applies to regular expressions
+// built by FilenameRegex afterwards.
+func CaseInsensitiveMatching(enable bool) {
+	caseInsensitiveMatching = enable
+}
+
+// normalizeLineEndings is an internal variable that holds the
+// state of line ending normalization
+var normalizeLineEndings = false
+
+// NormalizeLineEndings makes ReadContent convert CRLF and CR line
+// endings to LF, so drivers and SplitStatements only see LF. Checksums
+// are computed from the normalized content, so files applied with CRLF
+// line endings before fail migrate.Verify once.
+func NormalizeLineEndings(enable bool) {
+	normalizeLineEndings = enable
+}
+
 // FilenameRegex builds regular expression stmt with given
 // filename extension from driver.
 func FilenameRegex(filenameExtension string) *regexp.Regexp {
-	return regexp.MustCompile(fmt.Sprintf(filenameRegex, filenameExtension))
+	pattern := filenameRegex
+	name := `_(.*)`
+	if treatBareAsUp {
+		pattern = bareFilenameRegex
+		name = `_(.*?)`
+	}
+	if caseInsensitiveMatching {
+		// everything after the name, i.e. direction and extension
+		pattern = strings.Replace(pattern, name, name+`(?i:`, 1)
+		pattern = strings.TrimSuffix(pattern, `$`) + `)$`
+	}
+	if semanticVersions {
+		pattern = strings.Replace(pattern, `^([0-9]+)`, `^([0-9]+\.[0-9]+\.[0-9]+)`, 1)
+	}
+	return regexp.MustCompile(fmt.Sprintf(pattern, filenameExtension))
+}
+
+// semverBase limits each component of a semantic version
+const semverBase = 1000000
+
+// ParseSemver encodes a semantic version like 1.10.0 as uint64, so
+// that numeric order equals semantic version precedence. Each
+// component must be below 1000000. Pre-release and build metadata
+// are not supported.
+func ParseSemver(s string) (uint64, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("Unable to parse semantic version '%v'", s)
+	}
+	var version uint64
+	for _, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 0)
+		if err != nil || n >= semverBase {
+			return 0, fmt.Errorf("Unable to parse semantic version '%v'", s)
+		}
+		version = version*semverBase + n
+	}
+	return version, nil
+}
+
+// FormatSemver formats a version encoded by ParseSemver.
+func FormatSemver(version uint64) string {
+	return fmt.Sprintf("%d.%d.%d", version/semverBase/semverBase, version/semverBase%semverBase, version%semverBase)
+}
+
+// CompareSemver compares two semantic versions by precedence. It returns
+// -1 if a < b, 0 if a == b and +1 if a > b. Versions which can't be parsed
+// are compared as strings and sort after valid ones.
+func CompareSemver(a, b string) int {
+	va, errA := ParseSemver(a)
+	vb, errB := ParseSemver(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	case va < vb:
+		return -1
+	case va > vb:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// FilenameRegexExtensions builds regular expression stmt matching
+// any of the given filename extensions.
+func FilenameRegexExtensions(filenameExtensions []string) *regexp.Regexp {
+	quoted := make([]string, len(filenameExtensions))
+	for i, ext := range filenameExtensions {
+		quoted[i] = regexp.QuoteMeta(ext)
+	}
+	return FilenameRegex("(?:" + strings.Join(quoted, "|") + ")")
 }
 
 // File represents one file on disk.
 // Example: 001_initial_plan_to_do_sth.up.sql
 type File struct {
+	// file system holding the file, nil for the operating
+	// system's, see ReadMigrationFilesFS
+	FS fs.FS
+
 	// absolute path to file
 	Path string
 
@@ -35,16 +174,53 @@ type File struct {
 	// version parsed from filename
 	Version uint64
 
+	// semantic version parsed from filename, see SemanticVersions.
+	// Version holds its encoding by ParseSemver.
+	VersionString string
+
 	// the actual migration name parsed from filename
 	Name string
 
 	// content of the file
 	Content []byte
 
+	// checksum of the content, set by the migrate package
+	// before the file is handed to the driver
+	Checksum string
+
+	// label of the run, e.g. a deploy id, set by the migrate package
+	// before the file is handed to the driver, see migrate.SetRunLabel
+	RunLabel string
+
 	// UP or DOWN migration
 	Direction direction.Direction
+
+	// versions this migration depends on, parsed from
+	// the "-- migrate:requires 3,4" directive
+	Requires []uint64
+
+	// expected duration of the migration, parsed from
+	// the "-- migrate:estimated 2m" directive
+	Estimated time.Duration
+
+	// function run instead of content for migrations
+	// written in Go, see migrate.RegisterGoMigration
+	GoFunc GoMigrationFunc
+
+	// true if the content mixes LF, CRLF or CR line endings,
+	// set by ReadContent before normalizing them
+	MixedLineEndings bool
+
+	// true for down files without content which only remove the
+	// version, e.g. of versions without down migration
+	VersionOnly bool
 }
 
+// GoMigrationFunc is a migration written in Go. It runs in the
+// transaction tx, which also records the version, so both are
+// committed together.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
 // Files is a slice of Files
 type Files []File
 
@@ -64,14 +240,189 @@ type MigrationFile struct {
 type MigrationFiles []MigrationFile
 
 // ReadContent reads the file's content if the content is empty
+// and parses its directives.
 func (f *File) ReadContent() error {
-	if len(f.Content) == 0 {
-		content, err := ioutil.ReadFile(path.Join(f.Path, f.FileName))
+	if len(f.Content) == 0 && f.GoFunc == nil && !f.VersionOnly {
+		var content []byte
+		var err error
+		if f.FS != nil {
+			content, err = fs.ReadFile(f.FS, path.Join(f.Path, f.FileName))
+		} else {
+			content, err = ioutil.ReadFile(path.Join(f.Path, f.FileName))
+		}
 		if err != nil {
 			return err
 		}
 		f.Content = content
 	}
+	if mixedLineEndings(f.Content) {
+		f.MixedLineEndings = true
+	}
+	if normalizeLineEndings {
+		f.Content = toLF(f.Content)
+	}
+	return f.parseDirectives()
+}
+
+// mixedLineEndings reports whether content uses more
+// than one of LF, CRLF and CR line endings
+func mixedLineEndings(content []byte) bool {
+	crlf := bytes.Count(content, []byte("\r\n"))
+	lf := bytes.Count(content, []byte("\n")) - crlf
+	cr := bytes.Count(content, []byte("\r")) - crlf
+	kinds := 0
+	for _, n := range []int{lf, crlf, cr} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	return kinds > 1
+}
+
+// toLF converts CRLF and CR line endings to LF
+func toLF(content []byte) []byte {
+	if !bytes.Contains(content, []byte("\r")) {
+		return content
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+}
+
+// directiveRegex matches directive lines like "-- migrate:requires 3,4"
+var directiveRegex = regexp.MustCompile(`(?m)^[ \t]*--[ \t]*migrate:([a-z-]+)[ \t]*(.*?)[ \t]*\r?$`)
+
+// Directive returns the arguments of the first directive with the given
+// name in the file's content. Directives are comment lines like:
+//
+// 		-- migrate:requires 3,4
+func (f *File) Directive(name string) (args string, ok bool) {
+	for _, m := range directiveRegex.FindAllSubmatch(f.Content, -1) {
+		if string(m[1]) == name {
+			return string(m[2]), true
+		}
+	}
+	return "", false
+}
+
+// parseDirectives parses the directives stored in File fields
+func (f *File) parseDirectives() error {
+	if args, ok := f.Directive("requires"); ok && f.Requires == nil {
+		requires := make([]uint64, 0)
+		for _, v := range strings.Split(args, ",") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			version, err := strconv.ParseUint(v, 10, 0)
+			if err != nil {
+				return fmt.Errorf("%s: unable to parse version '%v' in requires directive", f.FileName, v)
+			}
+			requires = append(requires, version)
+		}
+		f.Requires = requires
+	}
+	if args, ok := f.Directive("estimated"); ok && f.Estimated == 0 {
+		estimated, err := time.ParseDuration(args)
+		if err != nil || estimated <= 0 {
+			return fmt.Errorf("%s: unable to parse duration '%v' in estimated directive", f.FileName, args)
+		}
+		f.Estimated = estimated
+	}
+	if args, ok := f.Directive("version"); ok {
+		return f.checkVersionDirective(args)
+	}
+	return nil
+}
+
+// checkVersionDirective returns an error unless the version of the
+// "-- migrate:version 5" directive matches the filename's, e.g. after
+// renumbering a file without updating references in its content.
+func (f *File) checkVersionDirective(args string) error {
+	var version uint64
+	var err error
+	if f.VersionString != "" {
+		version, err = ParseSemver(args)
+	} else {
+		version, err = strconv.ParseUint(args, 10, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: unable to parse version '%v' in version directive", f.FileName, args)
+	}
+	if version != f.Version {
+		return fmt.Errorf("%s: version directive %v doesn't match the filename", f.FileName, args)
+	}
+	return nil
+}
+
+// TopoSort returns all up migration files ordered by their dependencies,
+// declared with the "-- migrate:requires" directive. Files without
+// dependencies between each other are ordered by version. TopoSort errors
+// on cyclic dependencies and on prerequisites without up migration file.
+func (mf MigrationFiles) TopoSort() (Files, error) {
+	sort.Sort(mf)
+	files := make(map[uint64]*File)
+	for _, migrationFile := range mf {
+		if migrationFile.UpFile == nil {
+			continue
+		}
+		if err := migrationFile.UpFile.ReadContent(); err != nil {
+			return nil, err
+		}
+		files[migrationFile.Version] = migrationFile.UpFile
+	}
+
+	// count unresolved prerequisites, remember dependants
+	pending := make(map[uint64]int)
+	dependants := make(map[uint64][]uint64)
+	for version, f := range files {
+		for _, required := range f.Requires {
+			if _, ok := files[required]; !ok {
+				return nil, fmt.Errorf("%s requires version %v, which has no up migration file", f.FileName, required)
+			}
+			pending[version] += 1
+			dependants[required] = append(dependants[required], version)
+		}
+	}
+
+	// mf is sorted, so this resolves ties by version
+	sorted := make(Files, 0, len(files))
+	done := make(map[uint64]bool)
+	for len(sorted) < len(files) {
+		progress := false
+		for _, migrationFile := range mf {
+			version := migrationFile.Version
+			if _, ok := files[version]; !ok || done[version] || pending[version] > 0 {
+				continue
+			}
+			sorted = append(sorted, *files[version])
+			done[version] = true
+			for _, dependant := range dependants[version] {
+				pending[dependant] -= 1
+			}
+			progress = true
+			break
+		}
+		if !progress {
+			cyclic := make([]string, 0)
+			for _, migrationFile := range mf {
+				if _, ok := files[migrationFile.Version]; ok && !done[migrationFile.Version] {
+					cyclic = append(cyclic, strconv.FormatUint(migrationFile.Version, 10))
+				}
+			}
+			return nil, fmt.Errorf("cyclic dependencies between versions %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return sorted, nil
+}
+
+// Find returns the migration file with the given version
+// or nil if there is none.
+func (mf MigrationFiles) Find(version uint64) *MigrationFile {
+	for i := range mf {
+		if mf[i].Version == version {
+			return &mf[i]
+		}
+	}
 	return nil
 }
 
@@ -151,31 +502,144 @@ func (mf *MigrationFiles) From(version uint64, relativeN int) (Files, error) {
 
 // ReadMigrationFiles reads all migration files from a given path
 func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files MigrationFiles, err error) {
+	return readMigrationFiles(nil, path, filenameRegex, false)
+}
+
+// ReadMigrationFilesFS reads all migration files from a given path in fsys,
+// e.g. an embed.FS. The content of the returned files is read from fsys.
+func ReadMigrationFilesFS(fsys fs.FS, path string, filenameRegex *regexp.Regexp) (files MigrationFiles, err error) {
+	return readMigrationFiles(fsys, path, filenameRegex, false)
+}
+
+// ReadMigrationFilesRecursive reads all migration files from a given path
+// and all of its subdirectories. Versions must be unique across the whole tree.
+func ReadMigrationFilesRecursive(path string, filenameRegex *regexp.Regexp) (files MigrationFiles, err error) {
+	return readMigrationFiles(nil, path, filenameRegex, true)
+}
+
+// DetectExtension returns the most common filename extension of the
+// migration files in path, e.g. "sql", as a fallback when no extension
+// is specified. Ties are broken alphabetically. It returns an error if
+// path contains no migration files.
+func DetectExtension(path string) (string, error) {
+	return detectExtension(nil, path)
+}
+
+// DetectExtensionFS is like DetectExtension for a path in fsys.
+func DetectExtensionFS(fsys fs.FS, path string) (string, error) {
+	return detectExtension(fsys, path)
+}
+
+func detectExtension(fsys fs.FS, path string) (string, error) {
+	ioFiles, err := listFiles(fsys, path, false)
+	if err != nil {
+		return "", err
+	}
+
+	// the last group of the regex is the extension
+	re := FilenameRegex(`([^.]+)`)
+	counts := make(map[string]int)
+	for _, f := range ioFiles {
+		if m := re.FindStringSubmatch(f.name); m != nil {
+			counts[m[len(m)-1]]++
+		}
+	}
+
+	ext := ""
+	for e, n := range counts {
+		if n > counts[ext] || (n == counts[ext] && e < ext) {
+			ext = e
+		}
+	}
+	if ext == "" {
+		return "", fmt.Errorf("no migration files found in %s", path)
+	}
+	return ext, nil
+}
+
+// ioFile is a file found in a migrations directory
+type ioFile struct {
+	dir  string
+	name string
+}
+
+// listFiles returns all files in path, optionally walking subdirectories
+func listFiles(fsys fs.FS, path string, recursive bool) ([]ioFile, error) {
+	files := make([]ioFile, 0)
+	if fsys != nil {
+		// file systems are only read non-recursively, see ReadMigrationFilesFS
+		entries, err := fs.ReadDir(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, ioFile{dir: path, name: e.Name()})
+			}
+		}
+		return files, nil
+	}
+	if !recursive {
+		ioFiles, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range ioFiles {
+			if !f.IsDir() {
+				files = append(files, ioFile{dir: path, name: f.Name()})
+			}
+		}
+		return files, nil
+	}
+
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, ioFile{dir: filepath.Dir(p), name: info.Name()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func readMigrationFiles(fsys fs.FS, path string, filenameRegex *regexp.Regexp, recursive bool) (files MigrationFiles, err error) {
 	// find all migration files in path
-	ioFiles, err := ioutil.ReadDir(path)
+	ioFiles, err := listFiles(fsys, path, recursive)
 	if err != nil {
 		return nil, err
 	}
+	return parseMigrationFiles(fsys, ioFiles, filenameRegex)
+}
+
+// parseMigrationFiles pairs the up and down files among ioFiles
+// matching filenameRegex. Other files are ignored.
+func parseMigrationFiles(fsys fs.FS, ioFiles []ioFile, filenameRegex *regexp.Regexp) (files MigrationFiles, err error) {
 	type tmpFile struct {
 		version  uint64
 		name     string
 		filename string
+		dir      string
 		d        direction.Direction
 	}
 	tmpFiles := make([]*tmpFile, 0)
 	tmpFileMap := map[uint64]map[direction.Direction]tmpFile{}
 	for _, file := range ioFiles {
-		version, name, d, err := parseFilenameSchema(file.Name(), filenameRegex)
+		version, name, d, err := parseFilenameSchema(file.name, filenameRegex)
 		if err == nil {
 			if _, ok := tmpFileMap[version]; !ok {
 				tmpFileMap[version] = map[direction.Direction]tmpFile{}
 			}
 			if existing, ok := tmpFileMap[version][d]; !ok {
-				tmpFileMap[version][d] = tmpFile{version: version, name: name, filename: file.Name(), d: d}
+				tmpFileMap[version][d] = tmpFile{version: version, name: name, filename: file.name, dir: file.dir, d: d}
 			} else {
-				return nil, fmt.Errorf("duplicate migration file version %d : %q and %q", version, existing.filename, file.Name())
+				return nil, fmt.Errorf("duplicate migration file version %d : %q and %q", version, filepath.Join(existing.dir, existing.filename), filepath.Join(file.dir, file.name))
 			}
-			tmpFiles = append(tmpFiles, &tmpFile{version, name, file.Name(), d})
+			tmpFiles = append(tmpFiles, &tmpFile{version, name, file.name, file.dir, d})
 		}
 	}
 
@@ -192,7 +656,8 @@ func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files Migrat
 			switch file.d {
 			case direction.Up:
 				migrationFile.UpFile = &File{
-					Path:      path,
+					FS:        fsys,
+					Path:      file.dir,
 					FileName:  file.filename,
 					Version:   file.version,
 					Name:      file.name,
@@ -202,7 +667,8 @@ func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files Migrat
 				lookFordirection = direction.Down
 			case direction.Down:
 				migrationFile.DownFile = &File{
-					Path:      path,
+					FS:        fsys,
+					Path:      file.dir,
 					FileName:  file.filename,
 					Version:   file.version,
 					Name:      file.name,
@@ -219,7 +685,8 @@ func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files Migrat
 					switch lookFordirection {
 					case direction.Up:
 						migrationFile.UpFile = &File{
-							Path:      path,
+							FS:        fsys,
+							Path:      file2.dir,
 							FileName:  file2.filename,
 							Version:   file.version,
 							Name:      file2.name,
@@ -228,7 +695,8 @@ func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files Migrat
 						}
 					case direction.Down:
 						migrationFile.DownFile = &File{
-							Path:      path,
+							FS:        fsys,
+							Path:      file2.dir,
 							FileName:  file2.filename,
 							Version:   file.version,
 							Name:      file2.name,
@@ -245,6 +713,16 @@ func ReadMigrationFiles(path string, filenameRegex *regexp.Regexp) (files Migrat
 		}
 	}
 
+	if semanticVersions {
+		for _, mf := range newFiles {
+			for _, f := range []*File{mf.UpFile, mf.DownFile} {
+				if f != nil {
+					f.VersionString = FormatSemver(f.Version)
+				}
+			}
+		}
+	}
+
 	sort.Sort(newFiles)
 	return newFiles, nil
 }
@@ -256,16 +734,21 @@ func parseFilenameSchema(filename string, filenameRegex *regexp.Regexp) (version
 		return 0, "", 0, errors.New("Unable to parse filename schema")
 	}
 
-	version, err = strconv.ParseUint(matches[1], 10, 0)
+	if strings.Contains(matches[1], ".") {
+		version, err = ParseSemver(matches[1])
+	} else {
+		version, err = strconv.ParseUint(matches[1], 10, 0)
+	}
 	if err != nil {
 		return 0, "", 0, errors.New(fmt.Sprintf("Unable to parse version '%v' in filename schema", matches[0]))
 	}
 
-	if matches[3] == "up" {
+	switch strings.ToLower(matches[3]) {
+	case "up", "": // empty for bare filenames
 		d = direction.Up
-	} else if matches[3] == "down" {
+	case "down":
 		d = direction.Down
-	} else {
+	default:
 		return 0, "", 0, errors.New(fmt.Sprintf("Unable to parse up|down '%v' in filename schema", matches[3]))
 	}
 
@@ -291,6 +774,87 @@ func (mf MigrationFiles) Swap(i, j int) {
 	mf[i], mf[j] = mf[j], mf[i]
 }
 
+// dollarTagRegex matches the opening tag of dollar-quoted strings like $$ or $body$
+var dollarTagRegex = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// SplitStatements splits content into statements separated by semicolons,
+// for drivers running statements one by one. Semicolons in quoted strings
+// and identifiers, dollar-quoted strings and comments don't separate
+// statements. Statements are trimmed, empty ones are left out.
+// Backslashes don't escape quotes, see SplitStatementsEscaped.
+func SplitStatements(content []byte) [][]byte {
+	return splitStatements(content, false)
+}
+
+// SplitStatementsEscaped is like SplitStatements, but a backslash in a
+// quoted string escapes the next character, like in MySQL's default
+// sql_mode, e.g. 'it\'s; fine' is a single string.
+func SplitStatementsEscaped(content []byte) [][]byte {
+	return splitStatements(content, true)
+}
+
+// splitStatements implements SplitStatements and SplitStatementsEscaped
+func splitStatements(content []byte, backslash bool) [][]byte {
+	stmts := make([][]byte, 0)
+	appendStmt := func(stmt []byte) {
+		if stmt = bytes.TrimSpace(stmt); len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(content); i++ {
+		// end is the offset of the closing delimiter, relative to i
+		end := 0
+		switch c := content[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			if end = closingQuote(content[i+1:], c, backslash && c != '`'); end >= 0 {
+				end += 1
+			}
+		case bytes.HasPrefix(content[i:], []byte("--")):
+			end = bytes.IndexByte(content[i:], '\n')
+		case bytes.HasPrefix(content[i:], []byte("/*")):
+			if end = bytes.Index(content[i+2:], []byte("*/")); end >= 0 {
+				end += 3
+			}
+		case c == '$':
+			if tag := dollarTagRegex.Find(content[i:]); tag != nil {
+				if end = bytes.Index(content[i+len(tag):], tag); end >= 0 {
+					end += 2*len(tag) - 1
+				}
+			}
+		case c == ';':
+			appendStmt(content[start:i])
+			start = i + 1
+		}
+		if end < 0 {
+			// unterminated, the rest belongs to the last statement
+			break
+		}
+		i += end
+	}
+	if start < len(content) {
+		appendStmt(content[start:])
+	}
+	return stmts
+}
+
+// closingQuote returns the index of the first quote in content, skipping
+// characters escaped by a backslash if backslash is true, or -1.
+func closingQuote(content []byte, quote byte, backslash bool) int {
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
 // LineColumnFromOffset reads data and returns line and column integer
 // for a given offset.
 func LineColumnFromOffset(data []byte, offset int) (line, column int) {
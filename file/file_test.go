@@ -1,11 +1,15 @@
 package file
 
 import (
+	"fmt"
 	"github.com/chr4/migrate/migrate/direction"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestParseFilenameSchema(t *testing.T) {
@@ -210,6 +214,157 @@ func TestFiles(t *testing.T) {
 
 }
 
+func TestFilenameRegexExtensions(t *testing.T) {
+	root, cleanFn, err := makeFiles("TestFilenameRegexExtensions",
+		"001_migration.up.sql",
+		"001_migration.down.sql",
+		"002_migration.up.psql",
+		"002_migration.down.psql",
+		"003_migration.up.txt",
+		"004_migration.up.xsql",
+	)
+	defer cleanFn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ReadMigrationFiles(root, FilenameRegexExtensions([]string{"sql", "psql"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %v", len(files))
+	}
+	if files[1].UpFile.FileName != "002_migration.up.psql" || files[1].DownFile.FileName != "002_migration.down.psql" {
+		t.Error("Expected psql files for version 2")
+	}
+
+	// the same version with two extensions is a duplicate
+	root2, cleanFn2, err := makeFiles("TestFilenameRegexExtensions",
+		"001_migration.up.sql",
+		"001_migration.up.psql",
+	)
+	defer cleanFn2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadMigrationFiles(root2, FilenameRegexExtensions([]string{"sql", "psql"})); err == nil {
+		t.Fatal("Expected duplicate migration file error")
+	}
+}
+
+func TestDirective(t *testing.T) {
+	f := File{Content: []byte("-- a comment\n  --  migrate:requires 3, 4 \nCREATE TABLE foo ();\n-- migrate:requires 5\n")}
+	args, ok := f.Directive("requires")
+	if !ok || args != "3, 4" {
+		t.Errorf("Expected requires directive '3, 4', got %q", args)
+	}
+	if _, ok := f.Directive("isolation"); ok {
+		t.Error("Expected no isolation directive")
+	}
+
+	if err := f.ReadContent(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Requires) != 2 || f.Requires[0] != 3 || f.Requires[1] != 4 {
+		t.Errorf("Expected requires [3 4], got %v", f.Requires)
+	}
+
+	f = File{FileName: "001_foo.up.sql", Content: []byte("-- migrate:requires 3,x\n")}
+	if err := f.ReadContent(); err == nil {
+		t.Error("Expected error for invalid version in requires directive")
+	}
+
+	f = File{Content: []byte("-- migrate:estimated 2m30s\nUPDATE foo SET bar = 1;")}
+	if err := f.ReadContent(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Estimated != 150*time.Second {
+		t.Errorf("Expected estimated 2m30s, got %v", f.Estimated)
+	}
+
+	f = File{FileName: "001_foo.up.sql", Content: []byte("-- migrate:estimated soon\n")}
+	if err := f.ReadContent(); err == nil {
+		t.Error("Expected error for invalid duration in estimated directive")
+	}
+
+	f = File{FileName: "005_foo.up.sql", Version: 5, Content: []byte("-- migrate:version 5\nSELECT 5;")}
+	if err := f.ReadContent(); err != nil {
+		t.Errorf("Expected matching version directive to pass, got %v", err)
+	}
+	f = File{FileName: "006_foo.up.sql", Version: 6, Content: []byte("-- migrate:version 5\nSELECT 5;")}
+	if err := f.ReadContent(); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("Expected error for mismatching version directive, got %v", err)
+	}
+	f = File{FileName: "1.2.0_foo.up.sql", Version: 1000002000000, VersionString: "1.2.0", Content: []byte("-- migrate:version 1.2.0\n")}
+	if err := f.ReadContent(); err != nil {
+		t.Errorf("Expected matching semantic version directive to pass, got %v", err)
+	}
+	f = File{FileName: "005_foo.up.sql", Version: 5, Content: []byte("-- migrate:version five\n")}
+	if err := f.ReadContent(); err == nil {
+		t.Error("Expected error for invalid version in version directive")
+	}
+}
+
+func TestTopoSort(t *testing.T) {
+	newMigrationFiles := func(requires map[uint64]string) MigrationFiles {
+		files := make(MigrationFiles, 0)
+		for version, content := range requires {
+			files = append(files, MigrationFile{
+				Version: version,
+				UpFile: &File{
+					FileName:  fmt.Sprintf("%03d_foo.up.sql", version),
+					Version:   version,
+					Content:   []byte(content + "\nSELECT 1;"),
+					Direction: direction.Up,
+				},
+			})
+		}
+		return files
+	}
+
+	// diamond: 1 requires 2 and 3, which both require 4
+	files := newMigrationFiles(map[uint64]string{
+		1: "-- migrate:requires 2,3",
+		2: "-- migrate:requires 4",
+		3: "-- migrate:requires 4",
+		4: "",
+		5: "",
+	})
+	sorted, err := files.TopoSort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []uint64{4, 2, 3, 1, 5}
+	if len(sorted) != len(expect) {
+		t.Fatalf("Expected %v files, got %v", len(expect), len(sorted))
+	}
+	for i, version := range expect {
+		if sorted[i].Version != version {
+			t.Fatalf("Expected order %v, got version %v at index %v", expect, sorted[i].Version, i)
+		}
+	}
+
+	// cycle
+	files = newMigrationFiles(map[uint64]string{
+		1: "-- migrate:requires 3",
+		2: "-- migrate:requires 1",
+		3: "-- migrate:requires 2",
+		4: "",
+	})
+	if _, err := files.TopoSort(); err == nil {
+		t.Error("Expected error for cyclic dependencies")
+	}
+
+	// missing prerequisite
+	files = newMigrationFiles(map[uint64]string{
+		1: "-- migrate:requires 9",
+	})
+	if _, err := files.TopoSort(); err == nil {
+		t.Error("Expected error for missing prerequisite")
+	}
+}
+
 func TestDuplicateFiles(t *testing.T) {
 	dups := []string{
 		"001_migration.up.sql",
@@ -229,6 +384,80 @@ func TestDuplicateFiles(t *testing.T) {
 	}
 }
 
+func TestReadMigrationFilesRecursive(t *testing.T) {
+	root, cleanFn, err := makeFiles("TestReadMigrationFilesRecursive",
+		"2015q3/001_migration.up.sql",
+		"2015q3/001_migration.down.sql",
+		"2015q3/003_migration.up.sql",
+		"2015q4/002_migration.up.sql",
+		"2015q4/004_migration.down.sql",
+		"005_migration.up.sql",
+	)
+	defer cleanFn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only top-level files without recursion
+	files, err := ReadMigrationFiles(root, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %v", len(files))
+	}
+
+	files, err = ReadMigrationFilesRecursive(root, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPaths := []string{"2015q3", "2015q4", "2015q3", "2015q4", ""}
+	if len(files) != len(expectPaths) {
+		t.Fatalf("Expected %v files, got %v", len(expectPaths), len(files))
+	}
+	for i, f := range files {
+		if f.Version != uint64(i+1) {
+			t.Errorf("Sort order is incorrect, expected version %v, got %v", i+1, f.Version)
+		}
+		mf := f.UpFile
+		if mf == nil {
+			mf = f.DownFile
+		}
+		if mf.Path != path.Join(root, expectPaths[i]) {
+			t.Errorf("Expected path %v, got %v", path.Join(root, expectPaths[i]), mf.Path)
+		}
+	}
+	if files[0].DownFile == nil || files[0].DownFile.Path != path.Join(root, "2015q3") {
+		t.Error("Missing down file for version 1")
+	}
+
+	// content is read from the subdirectory
+	if err := ioutil.WriteFile(path.Join(root, "2015q4", "002_migration.up.sql"), []byte("test"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := files[1].UpFile.ReadContent(); err != nil {
+		t.Fatal(err)
+	}
+	if string(files[1].UpFile.Content) != "test" {
+		t.Error("Read content is wrong")
+	}
+}
+
+func TestReadMigrationFilesRecursiveDuplicates(t *testing.T) {
+	root, cleanFn, err := makeFiles("TestReadMigrationFilesRecursiveDuplicates",
+		"2015q3/001_migration.up.sql",
+		"2015q4/001_duplicate.up.sql",
+	)
+	defer cleanFn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadMigrationFilesRecursive(root, FilenameRegex("sql")); err == nil {
+		t.Fatal("Expected duplicate migration file error")
+	}
+}
+
 // makeFiles takes an identifier, and a list of file names and uses them to create a temporary
 // directory populated with files named with the names passed in.  makeFiles returns the root
 // directory name, and a func suitable for a defer cleanup to remove the temporary files after
@@ -245,9 +474,375 @@ func makeFiles(testname string, names ...string) (root string, cleanup func(), e
 	}
 
 	for _, name := range names {
+		if err = os.MkdirAll(path.Dir(path.Join(root, name)), 0755); err != nil {
+			return
+		}
 		if err = ioutil.WriteFile(path.Join(root, name), nil, 0755); err != nil {
 			return
 		}
 	}
 	return
 }
+
+func TestTreatBareAsUp(t *testing.T) {
+	root, cleanFn, err := makeFiles("TestTreatBareAsUp",
+		"001_migration.up.sql",
+		"001_migration.down.sql",
+		"002_bare.sql",
+		"003_dotted.name.sql",
+	)
+	defer cleanFn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bare files are ignored by default
+	files, err := ReadMigrationFiles(root, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 file, got %v", len(files))
+	}
+
+	TreatBareAsUp(true)
+	defer TreatBareAsUp(false)
+
+	files, err = ReadMigrationFiles(root, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %v", len(files))
+	}
+	if files[0].UpFile == nil || files[0].DownFile == nil || files[0].UpFile.Name != "migration" {
+		t.Error("Expected up and down file for version 1")
+	}
+	expectNames := map[uint64]string{2: "bare", 3: "dotted.name"}
+	for _, f := range files[1:] {
+		if f.UpFile == nil || f.UpFile.Direction != direction.Up {
+			t.Fatalf("Expected up file for version %v", f.Version)
+		}
+		if f.DownFile != nil {
+			t.Errorf("Expected no down file for version %v", f.Version)
+		}
+		if f.UpFile.Name != expectNames[f.Version] {
+			t.Errorf("Expected name %v, got %v", expectNames[f.Version], f.UpFile.Name)
+		}
+	}
+
+	downFiles, _ := files.ToFirstFrom(3)
+	if len(downFiles) != 1 || downFiles[0].Version != 1 {
+		t.Errorf("Expected only down file for version 1, got %v", downFiles)
+	}
+}
+
+func TestSemanticVersions(t *testing.T) {
+	root, cleanFn, err := makeFiles("TestSemanticVersions",
+		"2.0.0_migration.up.sql",
+		"1.10.0_migration.up.sql",
+		"1.0.1_migration.up.sql",
+		"1.0.1_migration.down.sql",
+		"1.0.0_migration.up.sql",
+		"001_integer.up.sql",
+	)
+	defer cleanFn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SemanticVersions(true)
+	defer SemanticVersions(false)
+
+	files, err := ReadMigrationFiles(root, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"1.0.0", "1.0.1", "1.10.0", "2.0.0"}
+	if len(files) != len(expect) {
+		t.Fatalf("Expected %v files, got %v", len(expect), len(files))
+	}
+	for i, version := range expect {
+		if files[i].UpFile.VersionString != version {
+			t.Errorf("Expected version %v at index %v, got %v", version, i, files[i].UpFile.VersionString)
+		}
+	}
+	if files[1].DownFile == nil || files[1].DownFile.VersionString != "1.0.1" {
+		t.Error("Expected down file for version 1.0.1")
+	}
+
+	for i := 1; i < len(expect); i++ {
+		if CompareSemver(expect[i-1], expect[i]) != -1 || CompareSemver(expect[i], expect[i-1]) != 1 {
+			t.Errorf("Expected %v < %v", expect[i-1], expect[i])
+		}
+	}
+	if CompareSemver("1.10.0", "1.10.0") != 0 {
+		t.Error("Expected equal versions")
+	}
+	if CompareSemver("1.0", "1.0.0") != 1 {
+		t.Error("Expected invalid version to sort last")
+	}
+
+	for _, invalid := range []string{"1.0", "1.0.0.0", "1.x.0", "1.1000000.0", ""} {
+		if _, err := ParseSemver(invalid); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+	if version, _ := ParseSemver("1.10.0"); FormatSemver(version) != "1.10.0" {
+		t.Errorf("Expected 1.10.0, got %v", FormatSemver(version))
+	}
+}
+
+func TestReadMigrationFilesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_migration.up.sql":     {Data: []byte("SELECT 1")},
+		"migrations/001_migration.down.sql":   {Data: []byte("SELECT 2")},
+		"migrations/sub/002_migration.up.sql": {Data: []byte("SELECT 3")},
+	}
+
+	files, err := ReadMigrationFilesFS(fsys, "migrations", FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].UpFile == nil || files[0].DownFile == nil {
+		t.Fatalf("Expected version 1 with up and down file, got %v", files)
+	}
+	if err := files[0].UpFile.ReadContent(); err != nil {
+		t.Fatal(err)
+	}
+	if string(files[0].UpFile.Content) != "SELECT 1" {
+		t.Errorf("Expected content to be read from fsys, got %q", files[0].UpFile.Content)
+	}
+}
+
+func TestDetectExtension(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/001_migration.up.sql":      {},
+		"sql/001_migration.down.sql":    {},
+		"sql/README.md":                 {},
+		"cql/001_migration.up.cql":      {},
+		"cql/002_migration.up.cql":      {},
+		"mixed/001_migration.up.sql":    {},
+		"mixed/002_migration.up.cql":    {},
+		"mixed/002_migration.down.cql":  {},
+		"mixed/003_migration.up.sh":     {},
+		"tie/001_migration.up.sql":      {},
+		"tie/002_migration.up.cql":      {},
+		"none/README.md":                {},
+		"none/sub/001_migration.up.sql": {},
+	}
+
+	var tests = []struct {
+		path      string
+		expect    string
+		expectErr bool
+	}{
+		{"sql", "sql", false},
+		{"cql", "cql", false},
+		{"mixed", "cql", false},
+		{"tie", "cql", false},
+		{"none", "", true},
+		{"missing", "", true},
+	}
+
+	for _, test := range tests {
+		ext, err := DetectExtensionFS(fsys, test.path)
+		if test.expectErr != (err != nil) {
+			t.Errorf("DetectExtensionFS(%q) returned error %v", test.path, err)
+		}
+		if ext != test.expect {
+			t.Errorf("DetectExtensionFS(%q) = %q, expected %q", test.path, ext, test.expect)
+		}
+	}
+
+	tmpdir, err := ioutil.TempDir("/tmp", "TestDetectExtension")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	for _, name := range []string{"001_migration.up.sql", "002_migration.up.sql", "003_migration.up.cql"} {
+		if err := ioutil.WriteFile(path.Join(tmpdir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ext, err := DetectExtension(tmpdir); err != nil || ext != "sql" {
+		t.Errorf("Expected extension sql, got %q (%v)", ext, err)
+	}
+}
+
+func TestCaseInsensitiveMatching(t *testing.T) {
+	filenames := []struct {
+		filename  string
+		version   uint64
+		name      string
+		direction direction.Direction
+	}{
+		{"001_Migration.UP.SQL", 1, "Migration", direction.Up},
+		{"001_Migration.Down.Sql", 1, "Migration", direction.Down},
+		{"002_migration.up.sql", 2, "migration", direction.Up},
+	}
+
+	if _, _, _, err := parseFilenameSchema(filenames[0].filename, FilenameRegex("sql")); err == nil {
+		t.Error("Expected mixed-case filename not to match by default")
+	}
+
+	CaseInsensitiveMatching(true)
+	defer CaseInsensitiveMatching(false)
+
+	for _, tt := range filenames {
+		version, name, d, err := parseFilenameSchema(tt.filename, FilenameRegex("sql"))
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", tt.filename, err)
+			continue
+		}
+		if version != tt.version || name != tt.name || d != tt.direction {
+			t.Errorf("Expected %v %v %v for %v, got %v %v %v", tt.version, tt.name, tt.direction, tt.filename, version, name, d)
+		}
+	}
+
+	// versions are still parsed strictly
+	if _, _, _, err := parseFilenameSchema("00a_migration.UP.SQL", FilenameRegex("sql")); err == nil {
+		t.Error("Expected invalid version not to match")
+	}
+
+	TreatBareAsUp(true)
+	defer TreatBareAsUp(false)
+	if version, _, d, err := parseFilenameSchema("003_migration.SQL", FilenameRegex("sql")); err != nil || version != 3 || d != direction.Up {
+		t.Errorf("Expected bare up file of version 3, got %v %v (%v)", version, d, err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		content string
+		expect  []string
+	}{
+		{"", []string{}},
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;\n\nSELECT 2;\n", []string{"SELECT 1", "SELECT 2"}},
+		{";;SELECT 1;;", []string{"SELECT 1"}},
+		{"SELECT ';'; SELECT 'it''s;'", []string{"SELECT ';'", "SELECT 'it''s;'"}},
+		{`SELECT "a;b" FROM t; SELECT 2`, []string{`SELECT "a;b" FROM t`, "SELECT 2"}},
+		{"SELECT `a;b`; SELECT 2", []string{"SELECT `a;b`", "SELECT 2"}},
+		{"-- comment;\nSELECT 1; /* multi;\nline */ SELECT 2", []string{"-- comment;\nSELECT 1", "/* multi;\nline */ SELECT 2"}},
+		{"CREATE FUNCTION f() AS $body$ SELECT 1; $body$; SELECT $$;$$", []string{"CREATE FUNCTION f() AS $body$ SELECT 1; $body$", "SELECT $$;$$"}},
+		{"SELECT $1; SELECT 2", []string{"SELECT $1", "SELECT 2"}},
+		{"SELECT 1; SELECT 'unterminated; SELECT 2", []string{"SELECT 1", "SELECT 'unterminated; SELECT 2"}},
+		{`SELECT 'C:\'; SELECT 2`, []string{`SELECT 'C:\'`, "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		stmts := SplitStatements([]byte(tt.content))
+		if len(stmts) != len(tt.expect) {
+			t.Errorf("Expected %v statements for %q, got %q", len(tt.expect), tt.content, stmts)
+			continue
+		}
+		for i := range stmts {
+			if string(stmts[i]) != tt.expect[i] {
+				t.Errorf("Expected statement %q for %q, got %q", tt.expect[i], tt.content, stmts[i])
+			}
+		}
+	}
+}
+
+func TestSplitStatementsEscaped(t *testing.T) {
+	tests := []struct {
+		content string
+		expect  []string
+	}{
+		{`SELECT 'it\'s; fine'; SELECT 2`, []string{`SELECT 'it\'s; fine'`, "SELECT 2"}},
+		{`SELECT "a\";b"; SELECT 2`, []string{`SELECT "a\";b"`, "SELECT 2"}},
+		{`SELECT 'a\\'; SELECT 2`, []string{`SELECT 'a\\'`, "SELECT 2"}},
+		{"SELECT `a\\`; SELECT 2", []string{"SELECT `a\\`", "SELECT 2"}},
+		{"SELECT 'it''s;'; SELECT 2", []string{"SELECT 'it''s;'", "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		stmts := SplitStatementsEscaped([]byte(tt.content))
+		if len(stmts) != len(tt.expect) {
+			t.Errorf("Expected %v statements for %q, got %q", len(tt.expect), tt.content, stmts)
+			continue
+		}
+		for i := range stmts {
+			if string(stmts[i]) != tt.expect[i] {
+				t.Errorf("Expected statement %q for %q, got %q", tt.expect[i], tt.content, stmts[i])
+			}
+		}
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_crlf.up.sql":  {Data: []byte("-- migrate:estimated 2m\r\nSELECT 1;\r\nSELECT 2;\r\n")},
+		"002_cr.up.sql":    {Data: []byte("-- old mac editor\rSELECT 1;\rSELECT 2;\r")},
+		"003_mixed.up.sql": {Data: []byte("SELECT 1;\r\nSELECT 2;\n")},
+		"004_lf.up.sql":    {Data: []byte("SELECT 1;\nSELECT 2;\n")},
+	}
+	read := func() MigrationFiles {
+		files, err := ReadMigrationFilesFS(fsys, ".", FilenameRegex("sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range files {
+			if err := mf.UpFile.ReadContent(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return files
+	}
+
+	// the comment of a CR only file swallows all statements
+	files := read()
+	if stmts := SplitStatements(files[1].UpFile.Content); len(stmts) != 1 {
+		t.Errorf("Expected CR line endings to break statement splitting, got %q", stmts)
+	}
+
+	NormalizeLineEndings(true)
+	defer NormalizeLineEndings(false)
+	files = read()
+	for _, mf := range files {
+		f := mf.UpFile
+		if strings.Contains(string(f.Content), "\r") {
+			t.Errorf("Expected %s to contain LF line endings only, got %q", f.FileName, f.Content)
+		}
+		if stmts := SplitStatements(f.Content); len(stmts) != 2 {
+			t.Errorf("Expected 2 statements in %s, got %q", f.FileName, stmts)
+		} else if string(stmts[1]) != "SELECT 2" {
+			t.Errorf("Expected second statement of %s to be SELECT 2, got %q", f.FileName, stmts[1])
+		}
+		if expect := f.Version == 3; f.MixedLineEndings != expect {
+			t.Errorf("Expected MixedLineEndings of %s to be %v", f.FileName, expect)
+		}
+	}
+	if files[0].UpFile.Estimated != 2*time.Minute {
+		t.Errorf("Expected directive of CRLF file to be parsed, got %v", files[0].UpFile.Estimated)
+	}
+}
+
+func TestReadMigrationFilesMap(t *testing.T) {
+	m := map[string][]byte{
+		"001_create_foo.up.sql":   []byte("CREATE TABLE foo ();"),
+		"001_create_foo.down.sql": []byte("DROP TABLE foo;"),
+		"002_empty.up.sql":        nil,
+		"README.md":               []byte("not a migration"),
+	}
+
+	files, err := ReadMigrationFilesMap(m, FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Version != 1 || files[1].Version != 2 || files[1].DownFile != nil {
+		t.Fatalf("Expected versions 1 and 2, got %v", files)
+	}
+	for _, f := range []*File{files[0].UpFile, files[0].DownFile, files[1].UpFile} {
+		if err := f.ReadContent(); err != nil {
+			t.Fatal(err)
+		}
+		if string(f.Content) != string(m[f.FileName]) {
+			t.Errorf("Expected content %q for %s, got %q", m[f.FileName], f.FileName, f.Content)
+		}
+	}
+	if files[0].UpFile.Name != "create_foo" || files[0].DownFile.Direction != direction.Down {
+		t.Errorf("Unexpected file %v", files[0].DownFile)
+	}
+}
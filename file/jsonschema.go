@@ -0,0 +1,167 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateJSON checks the content of all migration files against a JSON
+// schema, e.g. the one of a driver whose migrations are JSON documents.
+// The returned error names each offending file and field. Only a subset
+// of JSON schema is supported: type, properties, required,
+// additionalProperties, items and enum. Files of drivers accepting
+// other extensions too, like JavaScript, are only checked if their
+// extension is json.
+func (mf MigrationFiles) ValidateJSON(schema []byte) error {
+	var s map[string]interface{}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("invalid JSON schema: %v", err)
+	}
+
+	problems := make([]string, 0)
+	for _, m := range mf {
+		for _, f := range []*File{m.UpFile, m.DownFile} {
+			if f == nil || f.GoFunc != nil || !strings.HasSuffix(f.FileName, ".json") {
+				continue
+			}
+			if err := f.ReadContent(); err != nil {
+				return err
+			}
+			for _, problem := range validateJSON(f.Content, s) {
+				problems = append(problems, f.FileName+": "+problem)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid migration files:\n" + strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// validateJSON returns all violations of schema by content
+func validateJSON(content []byte, schema map[string]interface{}) []string {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return validateValue("$", value, schema)
+}
+
+// validateValue checks value at path against schema
+func validateValue(path string, value interface{}, schema map[string]interface{}) []string {
+	if t, ok := schema["type"]; ok && !matchesType(value, t) {
+		return []string{fmt.Sprintf("%s: expected %v, got %s", path, formatType(t), jsonType(value))}
+	}
+	problems := make([]string, 0)
+	if enum, ok := schema["enum"].([]interface{}); ok && !inEnum(value, enum) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, present := v[name]; !present {
+						problems = append(problems, fmt.Sprintf("%s.%s: is required", path, name))
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if p, ok := properties[key].(map[string]interface{}); ok {
+				problems = append(problems, validateValue(path+"."+key, v[key], p)...)
+			} else if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				problems = append(problems, fmt.Sprintf("%s.%s: is not allowed", path, key))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, validateValue(fmt.Sprintf("%s[%d]", path, i), item, items)...)
+			}
+		}
+	}
+	return problems
+}
+
+// matchesType reports whether value is of type t, which is
+// a type name or a list of type names
+func matchesType(value interface{}, t interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		actual := jsonType(value)
+		return actual == t || (t == "number" && actual == "integer")
+	case []interface{}:
+		for _, name := range t {
+			if matchesType(value, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatType formats a type name or a list of type names
+func formatType(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		parts := make([]string, 0, len(names))
+		for _, name := range names {
+			parts = append(parts, fmt.Sprint(name))
+		}
+		return strings.Join(parts, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// jsonType returns the JSON schema type name of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inEnum reports whether value equals one of the enum values
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if n, ok := value.(json.Number); ok {
+			if f, ok := e.(float64); ok {
+				if vf, err := n.Float64(); err == nil && vf == f {
+					return true
+				}
+			}
+			continue
+		}
+		if fmt.Sprint(e) == fmt.Sprint(value) && jsonType(value) == jsonType(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package file
+
+import (
+	"strings"
+	"testing"
+)
+
+var testJSONSchema = []byte(`{
+	"type": "array",
+	"items": {
+		"type": "object",
+		"required": ["op", "collection"],
+		"additionalProperties": false,
+		"properties": {
+			"op": {"enum": ["createIndex", "dropIndex"]},
+			"collection": {"type": "string"},
+			"keys": {"type": "object"},
+			"unique": {"type": "boolean"},
+			"ttl": {"type": ["integer", "null"]}
+		}
+	}
+}`)
+
+func TestValidateJSON(t *testing.T) {
+	var tests = []struct {
+		content string
+		expect  []string
+	}{
+		{`[{"op": "createIndex", "collection": "users", "keys": {"email": 1}, "unique": true, "ttl": null}]`, nil},
+		{`[]`, nil},
+		{`{"op": "createIndex"}`, []string{"$: expected array, got object"}},
+		{`[{"op": "createIndex"}]`, []string{"$[0].collection: is required"}},
+		{`[{"op": "rename", "collection": "users"}]`, []string{"$[0].op: rename is not one of [createIndex dropIndex]"}},
+		{`[{"op": "dropIndex", "collection": 1, "unique": "yes"}]`, []string{"$[0].collection: expected string, got integer", "$[0].unique: expected boolean, got string"}},
+		{`[{"op": "dropIndex", "collection": "users", "ttl": 1.5}]`, []string{"$[0].ttl: expected integer or null, got number"}},
+		{`[{"op": "dropIndex", "collection": "users", "sparse": true}]`, []string{"$[0].sparse: is not allowed"}},
+		{`[{"op": "dropIndex",}]`, []string{"invalid JSON"}},
+	}
+
+	for _, test := range tests {
+		files := MigrationFiles{{Version: 1, UpFile: &File{FileName: "001_foo.up.json", Content: []byte(test.content)}}}
+		err := files.ValidateJSON(testJSONSchema)
+		if len(test.expect) == 0 {
+			if err != nil {
+				t.Errorf("Expected %s to be valid, got %v", test.content, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("Expected %s to be invalid", test.content)
+			continue
+		}
+		for _, expect := range test.expect {
+			if !strings.Contains(err.Error(), "001_foo.up.json: "+expect) {
+				t.Errorf("Expected %q in error for %s, got %v", expect, test.content, err)
+			}
+		}
+	}
+
+	script := MigrationFiles{{Version: 1, UpFile: &File{FileName: "001_foo.up.js", Content: []byte("db.users.drop()")}}}
+	if err := script.ValidateJSON(testJSONSchema); err != nil {
+		t.Errorf("Expected files with other extensions to be skipped, got %v", err)
+	}
+
+	if err := (MigrationFiles{}).ValidateJSON([]byte("{")); err == nil || !strings.Contains(err.Error(), "invalid JSON schema") {
+		t.Errorf("Expected invalid schema error, got %v", err)
+	}
+}
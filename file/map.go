@@ -0,0 +1,65 @@
+package file
+
+import (
+	"bytes"
+	"io/fs"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// ReadMigrationFilesMap reads all migration files from m, which maps
+// filenames to their content, e.g. to embed a few migrations in a small
+// tool or a test without any files. The content is loaded already, so
+// ReadContent doesn't touch the file system.
+func ReadMigrationFilesMap(m map[string][]byte, filenameRegex *regexp.Regexp) (files MigrationFiles, err error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ioFiles := make([]ioFile, 0, len(names))
+	for _, name := range names {
+		ioFiles = append(ioFiles, ioFile{dir: ".", name: name})
+	}
+
+	fsys := mapFS(m)
+	files, err = parseMigrationFiles(fsys, ioFiles, filenameRegex)
+	if err != nil {
+		return nil, err
+	}
+	for _, mf := range files {
+		for _, f := range []*File{mf.UpFile, mf.DownFile} {
+			if f != nil {
+				f.Content = m[f.FileName]
+			}
+		}
+	}
+	return files, nil
+}
+
+// mapFS is a read-only file system of the files in a map. It serves
+// files with empty content, which ReadContent reads again.
+type mapFS map[string][]byte
+
+func (m mapFS) Open(name string) (fs.File, error) {
+	content, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &mapFile{Reader: bytes.NewReader(content), name: name}, nil
+}
+
+// mapFile is a file opened from a mapFS
+type mapFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (f *mapFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *mapFile) Close() error               { return nil }
+func (f *mapFile) Name() string               { return f.name }
+func (f *mapFile) Mode() fs.FileMode          { return 0444 }
+func (f *mapFile) ModTime() time.Time         { return time.Time{} }
+func (f *mapFile) IsDir() bool                { return false }
+func (f *mapFile) Sys() interface{}           { return nil }
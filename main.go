@@ -12,10 +12,28 @@ import (
 
 	"github.com/fatih/color"
 	_ "github.com/chr4/migrate/driver/bash"
+	_ "github.com/chr4/migrate/driver/bigquery"
 	_ "github.com/chr4/migrate/driver/cassandra"
+	_ "github.com/chr4/migrate/driver/crate"
+	_ "github.com/chr4/migrate/driver/crdb"
+	_ "github.com/chr4/migrate/driver/duckdb"
+	_ "github.com/chr4/migrate/driver/dynamodb"
+	_ "github.com/chr4/migrate/driver/elasticsearch"
+	_ "github.com/chr4/migrate/driver/hana"
+	_ "github.com/chr4/migrate/driver/mongodb"
+	_ "github.com/chr4/migrate/driver/mssql"
 	_ "github.com/chr4/migrate/driver/mysql"
+	_ "github.com/chr4/migrate/driver/neo4j"
+	_ "github.com/chr4/migrate/driver/oracle"
 	_ "github.com/chr4/migrate/driver/postgres"
+	_ "github.com/chr4/migrate/driver/redshift"
+	_ "github.com/chr4/migrate/driver/snowflake"
+	_ "github.com/chr4/migrate/driver/spanner"
 	_ "github.com/chr4/migrate/driver/sqlite3"
+	_ "github.com/chr4/migrate/driver/tidb"
+	_ "github.com/chr4/migrate/driver/trino"
+	_ "github.com/chr4/migrate/driver/vertica"
+	_ "github.com/chr4/migrate/driver/yugabyte"
 	"github.com/chr4/migrate/file"
 	"github.com/chr4/migrate/migrate"
 	"github.com/chr4/migrate/migrate/direction"
@@ -0,0 +1,42 @@
+package migrate
+
+import (
+	"path/filepath"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// baselineFile and baselineVersion are internal variables that
+// hold the baseline schema applied to fresh databases
+var baselineFile string
+var baselineVersion uint64
+
+// SetBaselineFile sets a schema file which Up applies to databases
+// without any migrations, recording it as version. Only migrations
+// after version run afterwards. Databases with a version are left
+// alone. Pass an empty path to disable the baseline.
+func SetBaselineFile(path string, version uint64) {
+	baselineFile = path
+	baselineVersion = version
+}
+
+// applyBaseline applies the baseline file if version is zero and
+// returns the version after applying it.
+func applyBaseline(d driver.Driver, version uint64) (uint64, error) {
+	if baselineFile == "" || version != 0 {
+		return version, nil
+	}
+	f := file.File{
+		Path:      filepath.Dir(baselineFile),
+		FileName:  filepath.Base(baselineFile),
+		Version:   baselineVersion,
+		Name:      "baseline",
+		Direction: direction.Up,
+	}
+	if err := migrateFile(d, f); err != nil {
+		return version, err
+	}
+	return baselineVersion, nil
+}
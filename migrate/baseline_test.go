@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBaselineFile(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+		"003_migration3.up.sql": "SELECT 1",
+		"schema.sql":            "CREATE TABLE baseline ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	SetBaselineFile(path.Join(tmpdir, "schema.sql"), 2)
+	defer SetBaselineFile("", 0)
+
+	// fresh database
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 2 {
+		t.Fatalf("Expected baseline and one migration, got %v files", len(fake.applied))
+	}
+	if f := fake.applied[0]; f.FileName != "schema.sql" || f.Version != 2 || string(f.Content) != "CREATE TABLE baseline ();" {
+		t.Errorf("Expected baseline recorded as version 2, got %v version %v", f.FileName, f.Version)
+	}
+	if fake.applied[1].Version != 3 {
+		t.Errorf("Expected version 3 after baseline, got %v", fake.applied[1].Version)
+	}
+	if version, _ := fake.Version(); version != 3 {
+		t.Errorf("Expected version 3, got %v", version)
+	}
+
+	// existing database
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fake.applied {
+		if f.FileName == "schema.sql" {
+			t.Fatal("Expected baseline to be skipped for existing database")
+		}
+	}
+	if len(fake.applied) != 3 {
+		t.Errorf("Expected 3 migrations, got %v", len(fake.applied))
+	}
+}
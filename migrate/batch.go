@@ -0,0 +1,25 @@
+package migrate
+
+import (
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// batchProgressFunc is an internal variable that holds
+// the batch progress callback
+var batchProgressFunc func(f file.File, processed int64)
+
+// SetBatchProgressFunc sets a callback which is called after each committed
+// batch of a batched migration with the number of rows processed so far,
+// so operators can watch long backfills. Drivers must implement
+// driver.BatchDriver. Pass nil to remove the callback.
+func SetBatchProgressFunc(fn func(f file.File, processed int64)) {
+	batchProgressFunc = fn
+}
+
+// configureBatchProgress passes the batch progress callback to the driver.
+func configureBatchProgress(d driver.Driver) {
+	if bd, ok := d.(driver.BatchDriver); ok {
+		bd.OnBatchProgress(batchProgressFunc)
+	}
+}
@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+)
+
+func TestSetBatchProgressFunc(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "-- migrate:batch\nUPDATE foo SET bar = 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var processed []int64
+	SetBatchProgressFunc(func(f file.File, n int64) {
+		if f.Version != 2 {
+			t.Errorf("Expected progress of version 2, got %v", f.Version)
+		}
+		processed = append(processed, n)
+	})
+	defer SetBatchProgressFunc(nil)
+
+	fakeBatch.reset()
+	fakeBatch.batches = []int64{100, 100, 50}
+	if err := Up("fakebatch://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	expect := []int64{100, 200, 250}
+	if len(processed) != len(expect) {
+		t.Fatalf("Expected %v progress calls, got %v", len(expect), processed)
+	}
+	for i := range expect {
+		if processed[i] != expect[i] {
+			t.Errorf("Expected %v processed rows, got %v", expect[i], processed[i])
+		}
+	}
+
+	// removing the callback stops reporting
+	SetBatchProgressFunc(nil)
+	processed = nil
+	fakeBatch.reset()
+	fakeBatch.batches = []int64{100}
+	if err := Up("fakebatch://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(processed) != 0 {
+		t.Errorf("Expected no progress calls, got %v", processed)
+	}
+}
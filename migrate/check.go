@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// postUpCheck is an internal variable that holds the
+// integrity check query run after Up
+var postUpCheck string
+
+// postUpCheckRows is the number of rows included in the error
+const postUpCheckRows = 5
+
+// SetPostUpCheck sets a query which runs after all migrations of Up
+// have been applied successfully, outside of their transactions. Up
+// returns an error including the first rows if the query returns any,
+// e.g. for foreign key consistency checks. Pass an empty query to
+// disable the check.
+func SetPostUpCheck(query string) {
+	postUpCheck = query
+}
+
+// ErrQueryNotSupported is returned if the driver doesn't
+// implement driver.QueryDriver.
+var ErrQueryNotSupported = errors.New("driver does not support queries")
+
+// runPostUpCheck runs the post up check query, if any.
+func runPostUpCheck(d driver.Driver) error {
+	if postUpCheck == "" {
+		return nil
+	}
+	qd, ok := d.(driver.QueryDriver)
+	if !ok {
+		return ErrQueryNotSupported
+	}
+
+	// fetch one more row to tell if there are more
+	rows, err := qd.QueryRows(postUpCheck, postUpCheckRows+1)
+	if err != nil {
+		return fmt.Errorf("post up check failed: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(rows))
+	for i, row := range rows {
+		if i == postUpCheckRows {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, strings.Join(row, ", "))
+	}
+	return errors.New("post up check returned rows:\n" + strings.Join(lines, "\n"))
+}
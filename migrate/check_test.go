@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPostUpCheck(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	query := "SELECT id FROM orders WHERE customer_id NOT IN (SELECT id FROM customers)"
+	SetPostUpCheck(query)
+	defer SetPostUpCheck("")
+
+	// passing check
+	fakeQuery.reset()
+	if err := Up("fakequery://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeQuery.queries) != 1 || fakeQuery.queries[0] != query {
+		t.Errorf("Expected check query to run once, got %v", fakeQuery.queries)
+	}
+
+	// failing check
+	fakeQuery.reset()
+	fakeQuery.rows = [][]string{{"1", "a"}, {"2", "b"}, {"3", "c"}, {"4", "d"}, {"5", "e"}, {"6", "f"}, {"7", "g"}}
+	err := Up("fakequery://", tmpdir)
+	if err == nil {
+		t.Fatal("Expected post up check to fail")
+	}
+	if !strings.Contains(err.Error(), "1, a\n2, b") || !strings.Contains(err.Error(), "5, e\n...") || strings.Contains(err.Error(), "6, f") {
+		t.Errorf("Expected the first 5 rows in error, got %v", err)
+	}
+	if version, _ := fakeQuery.Version(); version != 1 {
+		t.Errorf("Expected migrations to stay applied, got version %v", version)
+	}
+
+	// drivers without queries
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != ErrQueryNotSupported {
+		t.Errorf("Expected ErrQueryNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"errors"
+	neturl "net/url"
+	"sort"
+	"sync"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// recordCheckpoints is an internal variable that holds the
+// state of checkpoint recording
+var recordCheckpoints = false
+
+// RecordCheckpoints makes Up remember the start version and each version
+// committed by a run on a driver with transactional DDL. If the run fails,
+// RollbackToCheckpoint reverts to any of them. Checkpoints are kept in
+// memory for the last run per url.
+func RecordCheckpoints(enable bool) {
+	recordCheckpoints = enable
+}
+
+// ErrNoCheckpoint is returned by RollbackToCheckpoint for versions
+// which aren't checkpoints of the last run on the url.
+var ErrNoCheckpoint = errors.New("version is no checkpoint of the last run")
+
+// checkpointRun holds the checkpoints of a run
+type checkpointRun struct {
+	migrationsPath string
+
+	// versions holds the start version and the
+	// committed versions in ascending order
+	versions []uint64
+}
+
+var (
+	checkpointsMu sync.Mutex
+	checkpoints   = make(map[string]*checkpointRun)
+)
+
+// saveCheckpoints records the committed versions of result as
+// checkpoints of the run on url, see RecordCheckpoints.
+func saveCheckpoints(d driver.Driver, url, migrationsPath string, result *Result) {
+	if !recordCheckpoints || !driver.TransactionalDDL(d) {
+		return
+	}
+	run := &checkpointRun{migrationsPath: migrationsPath, versions: []uint64{result.StartVersion}}
+	for _, fr := range result.Files {
+		// files rolled back by AutoRollback are above the final version
+		if fr.Err == nil && fr.File.Version <= result.Version {
+			run.versions = append(run.versions, fr.File.Version)
+		}
+	}
+	sort.Slice(run.versions, func(i, j int) bool { return run.versions[i] < run.versions[j] })
+
+	checkpointsMu.Lock()
+	defer checkpointsMu.Unlock()
+	checkpoints[checkpointKey(url)] = run
+}
+
+// checkpointKey returns the key of the checkpoints of url, which is
+// the same for urls only differing in x-allow-down and the order of
+// their params
+func checkpointKey(url string) string {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return url
+	}
+	query := u.Query()
+	query.Del("x-allow-down")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// RollbackToCheckpoint reverts the last run of Up on url to version,
+// a checkpoint recorded by RecordCheckpoints, by running the down
+// migrations of the versions the run committed after it. Other versions
+// are left alone. It requires AllowDown.
+func RollbackToCheckpoint(url string, version uint64) error {
+	key := checkpointKey(url)
+	url, err := checkAllowDown(url)
+	if err != nil {
+		return err
+	}
+
+	checkpointsMu.Lock()
+	run := checkpoints[key]
+	checkpointsMu.Unlock()
+	if run == nil {
+		return ErrNoCheckpoint
+	}
+	i := sort.Search(len(run.versions), func(i int) bool { return run.versions[i] >= version })
+	if i == len(run.versions) || run.versions[i] != version {
+		return ErrNoCheckpoint
+	}
+
+	d, files, current, err := initDriverAndReadMigrationFilesAndGetVersion(url, run.migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	downFiles := make(file.Files, 0)
+	for j := len(run.versions) - 1; j > i; j-- {
+		if run.versions[j] > current {
+			continue
+		}
+		f, err := downFile(files, run.versions[j])
+		if err != nil {
+			return err
+		}
+		downFiles = append(downFiles, *f)
+	}
+	if err := migrateFiles(d, downFiles); err != nil {
+		return err
+	}
+
+	checkpointsMu.Lock()
+	defer checkpointsMu.Unlock()
+	run.versions = run.versions[:i+1]
+	return nil
+}
@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestRollbackToCheckpoint(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+	RecordCheckpoints(true)
+	defer RecordCheckpoints(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+		"004_migration4.up.sql":   "SELECT 1",
+		"004_migration4.down.sql": "SELECT 1",
+		"005_migration5.up.sql":   "FAIL",
+		"005_migration5.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	// the run starts at version 1
+	fakeTx.reset()
+	if err := Migrate("faketx://", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Up("faketx://", tmpdir); err == nil {
+		t.Fatal("Expected version 5 to fail")
+	}
+	if version, _ := fakeTx.Version(); version != 4 {
+		t.Fatalf("Expected version 4, got %v", version)
+	}
+
+	if err := RollbackToCheckpoint("faketx://", 5); err != ErrNoCheckpoint {
+		t.Errorf("Expected ErrNoCheckpoint for the failed version, got %v", err)
+	}
+
+	fakeTx.applied = nil
+	if err := RollbackToCheckpoint("faketx://", 2); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeTx.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+	if len(fakeTx.applied) != 2 || fakeTx.applied[0].Version != 4 || fakeTx.applied[1].Version != 3 || fakeTx.applied[0].Direction != direction.Down {
+		t.Errorf("Expected down migrations of versions 4 and 3, got %v", fakeTx.applied)
+	}
+
+	// versions before the run are no checkpoints,
+	// rolled back ones are no longer
+	if err := RollbackToCheckpoint("faketx://", 0); err != ErrNoCheckpoint {
+		t.Errorf("Expected ErrNoCheckpoint for version before the run, got %v", err)
+	}
+	if err := RollbackToCheckpoint("faketx://", 3); err != ErrNoCheckpoint {
+		t.Errorf("Expected ErrNoCheckpoint for rolled back version, got %v", err)
+	}
+	if err := RollbackToCheckpoint("faketx://", 1); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeTx.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	// non-transactional drivers record no checkpoints
+	fakeNonTx.reset()
+	if err := Up("fakenontx://", tmpdir); err == nil {
+		t.Fatal("Expected version 5 to fail")
+	}
+	if err := RollbackToCheckpoint("fakenontx://", 2); err != ErrNoCheckpoint {
+		t.Errorf("Expected ErrNoCheckpoint, got %v", err)
+	}
+
+	AllowDown(false)
+	if err := RollbackToCheckpoint("faketx://", 1); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed, got %v", err)
+	}
+}
+
+func TestRollbackToCheckpointURL(t *testing.T) {
+	RecordCheckpoints(true)
+	defer RecordCheckpoints(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "FAIL",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeTx.reset()
+	if _, err := UpWithResult("faketx://?x-allow-down=true&b=2&a=1", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+
+	// the checkpoints are found for the url as passed to UpWithResult
+	if err := RollbackToCheckpoint("faketx://?x-allow-down=true&b=2&a=1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeTx.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	// and for the same url with its params in another order
+	if err := RollbackToCheckpoint("faketx://?a=1&x-allow-down=true&b=2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeTx.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+}
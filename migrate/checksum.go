@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// checksumFunc is an internal variable that holds the
+// checksum algorithm for migration file contents.
+var checksumFunc = sha256Checksum
+
+// sha256Checksum is the default checksum algorithm.
+func sha256Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// SetChecksumFunc sets the checksum algorithm for migration file contents.
+// It defaults to hex encoded SHA-256. Pass nil to restore the default.
+// Changing the algorithm invalidates checksums stored by earlier runs.
+func SetChecksumFunc(fn func([]byte) string) {
+	if fn == nil {
+		fn = sha256Checksum
+	}
+	checksumFunc = fn
+}
+
+// normalizeForChecksum is an internal variable that holds the state
+// of content normalization before hashing
+var normalizeForChecksum = false
+
+// NormalizeForChecksum strips comments and collapses whitespace before
+// migration file contents are hashed, so reformatting a file doesn't
+// fail Verify. Whitespace and comments within quotes are kept. It must
+// be set both when applying and when verifying migrations.
+func NormalizeForChecksum(enable bool) {
+	normalizeForChecksum = enable
+}
+
+// checksum returns the checksum of content using checksumFunc.
+func checksum(content []byte) string {
+	if normalizeForChecksum {
+		content = normalizeSQL(content)
+	}
+	return checksumFunc(content)
+}
+
+// normalizeSQL removes -- and /* */ comments and replaces each run
+// of whitespace outside of quotes with a single space, or nothing
+// next to punctuation.
+func normalizeSQL(content []byte) []byte {
+	out := make([]byte, 0, len(content))
+	space := false
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '-' && bytes.HasPrefix(content[i:], []byte("--")):
+			if end := bytes.IndexByte(content[i:], '\n'); end >= 0 {
+				i += end - 1
+			} else {
+				i = len(content)
+			}
+			space = true
+		case c == '/' && bytes.HasPrefix(content[i:], []byte("/*")):
+			if end := bytes.Index(content[i+2:], []byte("*/")); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(content)
+			}
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		default:
+			if space && len(out) > 0 && !isPunctuation(out[len(out)-1]) && !isPunctuation(c) {
+				out = append(out, ' ')
+			}
+			space = false
+
+			// copy quoted strings and identifiers as is
+			next := i + 1
+			if c == '\'' || c == '"' {
+				if end := bytes.IndexByte(content[i+1:], c); end >= 0 {
+					next = i + end + 2
+				} else {
+					next = len(content)
+				}
+			}
+			out = append(out, content[i:next]...)
+			i = next - 1
+		}
+	}
+	return out
+}
+
+// isPunctuation reports whether whitespace around c is insignificant.
+func isPunctuation(c byte) bool {
+	return c == '(' || c == ')' || c == ',' || c == ';'
+}
+
+// ErrChecksumNotSupported is returned if the driver doesn't
+// implement driver.Checksummer.
+var ErrChecksumNotSupported = errors.New("driver does not support checksums")
+
+// Verify compares the stored checksums of all applied migrations
+// with the checksums of the up migration files on disk. It returns
+// an error listing every version that doesn't match.
+func Verify(url, migrationsPath string) error {
+	d, files, _, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	c, ok := d.(driver.Checksummer)
+	if !ok {
+		return ErrChecksumNotSupported
+	}
+	checksums, err := c.Checksums()
+	if err != nil {
+		return err
+	}
+
+	versions := make([]uint64, 0, len(checksums))
+	for version := range checksums {
+		versions = append(versions, version)
+	}
+	sort.Sort(uint64Slice(versions))
+
+	mismatches := make([]string, 0)
+	for _, version := range versions {
+		mf := files.Find(version)
+		if mf == nil || mf.UpFile == nil {
+			mismatches = append(mismatches, fmt.Sprintf("version %v: up migration file is missing", version))
+			continue
+		}
+		f := mf.UpFile
+		if err := f.ReadContent(); err != nil {
+			return err
+		}
+		if sum := checksum(f.Content); sum != checksums[version] {
+			mismatches = append(mismatches, fmt.Sprintf("version %v: %s has checksum %s, expected %s", version, f.FileName, sum, checksums[version]))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return errors.New("checksum mismatch:\n" + strings.Join(mismatches, "\n"))
+	}
+	return nil
+}
+
+// VerifyLatest compares the stored checksum of the most recently applied
+// version with the checksum of its up migration file, e.g. as a cheap
+// guard on startup against edits of the last migration. Unlike Verify,
+// it doesn't acquire the migration lock. Versions applied without a
+// checksum pass. Drivers not implementing driver.LatestChecksummer
+// read all stored checksums.
+func VerifyLatest(url, migrationsPath string) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	version, stored, err := latestChecksum(d)
+	if err != nil || version == 0 || stored == "" {
+		return err
+	}
+
+	files, err := readMigrationFiles(migrationsPath, filenameRegex(d))
+	if err != nil {
+		return err
+	}
+	mf := files.Find(version)
+	if mf == nil || mf.UpFile == nil {
+		return fmt.Errorf("checksum mismatch: version %v: up migration file is missing", version)
+	}
+	f := mf.UpFile
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if sum := checksum(f.Content); sum != stored {
+		return fmt.Errorf("checksum mismatch: version %v: %s has checksum %s, expected %s", version, f.FileName, sum, stored)
+	}
+	return nil
+}
+
+// latestChecksum returns the latest version of d and its stored checksum,
+// which is empty if the version was applied without one
+func latestChecksum(d driver.Driver) (uint64, string, error) {
+	if l, ok := d.(driver.LatestChecksummer); ok {
+		return l.LatestChecksum()
+	}
+	c, ok := d.(driver.Checksummer)
+	if !ok {
+		return 0, "", ErrChecksumNotSupported
+	}
+	version, err := d.Version()
+	if err != nil || version == 0 {
+		return 0, "", err
+	}
+	checksums, err := c.Checksums()
+	if err != nil {
+		return 0, "", err
+	}
+	return version, checksums[version], nil
+}
+
+// uint64Slice attaches the methods of sort.Interface to []uint64.
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
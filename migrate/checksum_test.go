@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestChecksumFunc(t *testing.T) {
+	crc := func(content []byte) string {
+		return fmt.Sprintf("%08x", crc32.ChecksumIEEE(content))
+	}
+	SetChecksumFunc(crc)
+	defer SetChecksumFunc(nil)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE foo ();",
+		"001_migration1.down.sql": "DROP TABLE foo;",
+		"002_migration2.up.sql":   "CREATE TABLE bar ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if expect := crc([]byte("CREATE TABLE foo ();")); fake.checksums[1] != expect {
+		t.Errorf("Expected checksum %v, got %v", expect, fake.checksums[1])
+	}
+	if err := Verify("fake://", tmpdir); err != nil {
+		t.Error(err)
+	}
+
+	// stored checksums don't match a different algorithm
+	SetChecksumFunc(nil)
+	if err := Verify("fake://", tmpdir); err == nil {
+		t.Error("Expected checksum mismatch with default algorithm")
+	}
+	SetChecksumFunc(crc)
+
+	// tampered file
+	if err := ioutil.WriteFile(path.Join(tmpdir, "002_migration2.up.sql"), []byte("CREATE TABLE baz ();"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("fake://", tmpdir); err == nil {
+		t.Error("Expected checksum mismatch for tampered file")
+	}
+}
+
+func TestVerifyDefaultChecksum(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE foo ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.checksums[1]) != 64 {
+		t.Errorf("Expected hex encoded SHA-256 checksum, got %v", fake.checksums[1])
+	}
+	if err := Verify("fake://", tmpdir); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyLatest(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE foo ();",
+		"002_migration2.up.sql": "CREATE TABLE bar ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := VerifyLatest("fake://", tmpdir); err != nil {
+		t.Errorf("Expected empty database to pass, got %v", err)
+	}
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLatest("fake://", tmpdir); err != nil {
+		t.Error(err)
+	}
+
+	// only the latest version is checked
+	if err := ioutil.WriteFile(path.Join(tmpdir, "001_migration1.up.sql"), []byte("CREATE TABLE baz ();"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLatest("fake://", tmpdir); err != nil {
+		t.Errorf("Expected tampered earlier file to be ignored, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(tmpdir, "002_migration2.up.sql"), []byte("CREATE TABLE baz ();"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLatest("fake://", tmpdir); err == nil || !strings.Contains(err.Error(), "version 2: 002_migration2.up.sql has checksum") {
+		t.Errorf("Expected checksum mismatch for tampered latest file, got %v", err)
+	}
+
+	if err := os.Remove(path.Join(tmpdir, "002_migration2.up.sql")); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLatest("fake://", tmpdir); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected error for missing latest file, got %v", err)
+	}
+	if fake.checksumScans != 0 {
+		t.Errorf("Expected only the latest checksum to be read, got %v reads of all checksums", fake.checksumScans)
+	}
+}
+
+func TestNormalizeForChecksum(t *testing.T) {
+	NormalizeForChecksum(true)
+	defer NormalizeForChecksum(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE foo (id int, name text DEFAULT 'a  b');",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	// reformatted and commented
+	reformatted := `-- create foo
+		CREATE TABLE foo (
+			id   int, /* primary key */
+			name text DEFAULT 'a  b'
+		);
+	`
+	if err := ioutil.WriteFile(path.Join(tmpdir, "001_migration1.up.sql"), []byte(reformatted), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("fake://", tmpdir); err != nil {
+		t.Errorf("Expected reformatted file to pass, got %v", err)
+	}
+
+	// whitespace within quotes is significant
+	changed := "CREATE TABLE foo (id int, name text DEFAULT 'a b');"
+	if err := ioutil.WriteFile(path.Join(tmpdir, "001_migration1.up.sql"), []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("fake://", tmpdir); err == nil {
+		t.Error("Expected checksum mismatch for changed file")
+	}
+
+	// without normalization the reformatted file fails
+	NormalizeForChecksum(false)
+	if err := ioutil.WriteFile(path.Join(tmpdir, "001_migration1.up.sql"), []byte(reformatted), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(tmpdir, "001_migration1.up.sql"), []byte("CREATE TABLE foo (id int, name text DEFAULT 'a  b');"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify("fake://", tmpdir); err == nil {
+		t.Error("Expected checksum mismatch without normalization")
+	}
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	var tests = []struct {
+		content string
+		expect  string
+	}{
+		{"SELECT  1;\n\n", "SELECT 1;"},
+		{"CREATE TABLE foo ( id int , name text );", "CREATE TABLE foo(id int,name text);"},
+		{"-- comment\nSELECT 1; -- trailing", "SELECT 1;"},
+		{"SELECT /* inline */ 1;", "SELECT 1;"},
+		{"SELECT '--  not a comment';", "SELECT '--  not a comment';"},
+		{`SELECT "a  b" FROM t;`, `SELECT "a  b" FROM t;`},
+		{"SELECT 'it''s';", "SELECT 'it''s';"},
+		{"SELECT 'unterminated", "SELECT 'unterminated"},
+		{"SELECT 1 /* unterminated", "SELECT 1"},
+	}
+	for _, test := range tests {
+		if got := string(normalizeSQL([]byte(test.content))); got != test.expect {
+			t.Errorf("normalizeSQL(%q) = %q, expected %q", test.content, got, test.expect)
+		}
+	}
+}
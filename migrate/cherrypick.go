@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// allowCherryPick is an internal variable that holds the state
+// of cherry-pick protection
+var allowCherryPick = false
+
+// AllowCherryPick enables functions which apply single migrations
+// outside of the regular version order, like ApplyReader. They are
+// meant for debugging and are disabled by default.
+func AllowCherryPick(allow bool) {
+	allowCherryPick = allow
+}
+
+// ErrCherryPickNotAllowed is returned by cherry-pick functions
+// unless AllowCherryPick(true) has been called.
+var ErrCherryPickNotAllowed = errors.New("cherry-picking migrations is not allowed, see AllowCherryPick")
+
+// ApplyReader reads the migration content from r, e.g. os.Stdin, and
+// applies it as migration of the given version and direction.
+// It requires AllowCherryPick(true).
+func ApplyReader(url string, r io.Reader, version uint64, d direction.Direction) error {
+	if !allowCherryPick {
+		return ErrCherryPickNotAllowed
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return errors.New("no migration content to apply")
+	}
+
+	drv, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+	if err := lock(drv); err != nil {
+		drv.Close()
+		return err
+	}
+	defer closeDriver(drv)
+
+	f := file.File{
+		FileName:  "<reader>",
+		Version:   version,
+		Name:      "reader",
+		Content:   content,
+		Direction: d,
+	}
+	return migrateFile(drv, f)
+}
+
+// ErrRefreshNotSupported is returned if the driver doesn't
+// implement driver.RefreshDriver.
+var ErrRefreshNotSupported = errors.New("driver does not support refreshing migrations")
+
+// Refresh runs the up migration of an applied version again without
+// recording the version, e.g. for idempotent migrations re-creating a
+// view. The stored checksum is left as is. It requires
+// AllowCherryPick(true).
+func Refresh(url, migrationsPath string, version uint64) error {
+	if !allowCherryPick {
+		return ErrCherryPickNotAllowed
+	}
+
+	d, files, current, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	rd, ok := d.(driver.RefreshDriver)
+	if !ok {
+		return ErrRefreshNotSupported
+	}
+	applied := version <= current
+	if vd, ok := d.(driver.VersionsDriver); ok {
+		versions, err := vd.Versions()
+		if err != nil {
+			return err
+		}
+		applied = false
+		for _, v := range versions {
+			if v.Version == version {
+				applied = true
+				break
+			}
+		}
+	}
+	if !applied {
+		return fmt.Errorf("version %v is not applied", version)
+	}
+
+	mf := files.Find(version)
+	if mf == nil || mf.UpFile == nil {
+		return fmt.Errorf("no up migration for version %v", version)
+	}
+	f := *mf.UpFile
+	if f.GoFunc != nil {
+		return fmt.Errorf("%s: Go migrations can't be refreshed", f.FileName)
+	}
+	return rd.Refresh(f)
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestApplyReader(t *testing.T) {
+	fake.reset()
+	if err := ApplyReader("fake://", strings.NewReader("SELECT 1"), 5, direction.Up); err != ErrCherryPickNotAllowed {
+		t.Fatalf("Expected ErrCherryPickNotAllowed, got %v", err)
+	}
+
+	AllowCherryPick(true)
+	defer AllowCherryPick(false)
+
+	if err := ApplyReader("fake://", strings.NewReader("CREATE TABLE foo ();"), 5, direction.Up); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 5 {
+		t.Errorf("Expected version 5, got %v", version)
+	}
+	if len(fake.applied) != 1 || string(fake.applied[0].Content) != "CREATE TABLE foo ();" {
+		t.Errorf("Expected content from reader to be applied, got %v", fake.applied)
+	}
+
+	if err := ApplyReader("fake://", strings.NewReader("DROP TABLE foo;"), 5, direction.Down); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+
+	if err := ApplyReader("fake://", strings.NewReader("FAIL"), 6, direction.Up); err == nil {
+		t.Error("Expected failing migration to return error")
+	}
+	if err := ApplyReader("fake://", strings.NewReader(""), 6, direction.Up); err == nil {
+		t.Error("Expected error for empty content")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE yolo (id INTEGER PRIMARY KEY, done BOOLEAN); INSERT INTO yolo (done) VALUES (0), (1);",
+		"002_migration2.up.sql": "DROP VIEW IF EXISTS yolo_view; CREATE VIEW yolo_view AS SELECT id FROM yolo WHERE done;",
+		"003_migration3.up.sql": "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+	driverUrl := "sqlite3://" + path.Join(tmpdir, "test.db")
+
+	if err := Refresh(driverUrl, tmpdir, 2); err != ErrCherryPickNotAllowed {
+		t.Fatalf("Expected ErrCherryPickNotAllowed, got %v", err)
+	}
+
+	AllowCherryPick(true)
+	defer AllowCherryPick(false)
+
+	if err := Migrate(driverUrl, tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-create the view with an updated definition
+	if err := ioutil.WriteFile(path.Join(tmpdir, "002_migration2.up.sql"), []byte("DROP VIEW IF EXISTS yolo_view; CREATE VIEW yolo_view AS SELECT id FROM yolo;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Refresh(driverUrl, tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	connection, err := sql.Open("sqlite3", path.Join(tmpdir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	var rows, versions int
+	if err := connection.QueryRow("SELECT count(*) FROM yolo_view").Scan(&rows); err != nil || rows != 2 {
+		t.Errorf("Expected the refreshed view to return 2 rows, got %v (%v)", rows, err)
+	}
+	if err := connection.QueryRow("SELECT count(*) FROM schema_migration WHERE version = 2").Scan(&versions); err != nil || versions != 1 {
+		t.Errorf("Expected a single row for version 2, got %v (%v)", versions, err)
+	}
+
+	if err := Refresh(driverUrl, tmpdir, 3); err == nil || !strings.Contains(err.Error(), "version 3 is not applied") {
+		t.Errorf("Expected error for pending version, got %v", err)
+	}
+
+	fake.reset()
+	if err := Refresh("fake://", tmpdir, 1); err != ErrRefreshNotSupported {
+		t.Errorf("Expected ErrRefreshNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrCloneNotSupported is returned if the driver doesn't
+// implement driver.CloneDriver.
+var ErrCloneNotSupported = errors.New("driver does not support cloning databases")
+
+// UpAgainstClone previews Up: it copies the database, applies all pending
+// migrations to the copy and drops it again. The database itself is not
+// modified, so migrations can be tested against realistic data. If a
+// migration fails, its error is returned along with the result.
+func UpAgainstClone(url, migrationsPath string) (*Result, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	cd, ok := d.(driver.CloneDriver)
+	if !ok {
+		return nil, ErrCloneNotSupported
+	}
+	cloneURL, err := cd.CreateClone()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := upClone(cloneURL, migrationsPath)
+	if dropErr := cd.DropClone(cloneURL); dropErr != nil {
+		if err != nil {
+			return result, fmt.Errorf("%v\ndropping clone failed: %v", err, dropErr)
+		}
+		return result, dropErr
+	}
+	return result, err
+}
+
+// upClone applies all pending migrations to the clone at url. The
+// registered driver is connected to the original database, so a new
+// instance is used, which is closed before the clone is dropped.
+func upClone(url, migrationsPath string) (*Result, error) {
+	d, err := driver.NewInstance(url)
+	if err != nil {
+		return nil, err
+	}
+	files, version, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex(d))
+	if err != nil {
+		return nil, err
+	}
+	defer closeDriver(d)
+
+	applyMigrationFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return nil, err
+	}
+	result := runWithResult(d, version, func(result *Result) error {
+		return migrateUpFiles(d, files, applyMigrationFiles, autoRollback, result)
+	})
+	return result, result.Err
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestUpAgainstClone(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeClone.reset()
+	fakeClone.versions[1] = true
+	result, err := UpAgainstClone("fakeclone://", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StartVersion != 1 || result.Version != 2 || len(result.Files) != 1 || result.Files[0].File.Version != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if version, _ := fakeClone.Version(); version != 1 {
+		t.Errorf("Expected source to remain at version 1, got %v", version)
+	}
+	if len(fakeClone.dropped) != 1 || fakeClone.dropped[0] != fakeClone.cloned[0] {
+		t.Errorf("Expected clone to be dropped, got %v", fakeClone.dropped)
+	}
+
+	// failures are reported and the clone is dropped anyway
+	failingdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 2",
+		"003_migration3.up.sql": "FAIL",
+	})
+	defer os.RemoveAll(failingdir)
+
+	result, err = UpAgainstClone("fakeclone://", failingdir)
+	if err == nil {
+		t.Fatal("Expected failing migration to return an error")
+	}
+	if result == nil || result.Err != err || result.Version != 2 || len(result.Files) != 2 || result.Files[1].Err == nil {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(fakeClone.dropped) != 2 {
+		t.Errorf("Expected clone to be dropped, got %v", fakeClone.dropped)
+	}
+
+	if _, err := UpAgainstClone("fake://", tmpdir); err != ErrCloneNotSupported {
+		t.Errorf("Expected ErrCloneNotSupported, got %v", err)
+	}
+}
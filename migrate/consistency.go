@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// consistencyTimeout is an internal variable that holds the
+// time to wait for DDL to become visible
+var consistencyTimeout time.Duration
+
+// SetConsistencyTimeout makes drivers of distributed databases wait
+// after each file until the tables and views it created or altered are
+// visible, before recording the version, so the next file sees them.
+// Waiting longer than timeout fails the migration. 0 disables waiting,
+// which is the default. See driver.ConsistencyWaiter.
+func SetConsistencyTimeout(timeout time.Duration) {
+	consistencyTimeout = timeout
+}
+
+// configureConsistency passes the consistency timeout to the driver.
+func configureConsistency(d driver.Driver) {
+	if cw, ok := d.(driver.ConsistencyWaiter); ok {
+		cw.SetConsistencyTimeout(consistencyTimeout)
+	}
+}
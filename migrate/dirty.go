@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrDirtyNotSupported is returned if the driver doesn't
+// implement driver.DirtyDriver.
+var ErrDirtyNotSupported = errors.New("driver does not support dirty state")
+
+// DirtyState returns the current version and whether a failed
+// migration left it dirty.
+func DirtyState(url string) (dirty bool, version uint64, err error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return false, 0, err
+	}
+	defer d.Close()
+
+	dd, ok := d.(driver.DirtyDriver)
+	if !ok {
+		return false, 0, ErrDirtyNotSupported
+	}
+	return dd.Dirty()
+}
+
+// ClearDirty clears the dirty flag after the database has been
+// fixed manually. The version is left unchanged.
+func ClearDirty(url string) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+
+	dd, ok := d.(driver.DirtyDriver)
+	if !ok {
+		d.Close()
+		return ErrDirtyNotSupported
+	}
+	if err := lock(d); err != nil {
+		d.Close()
+		return err
+	}
+	defer closeDriver(d)
+
+	return dd.ClearDirty()
+}
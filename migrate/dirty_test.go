@@ -0,0 +1,44 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDirtyState(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	if _, _, err := DirtyState("fake://"); err != ErrDirtyNotSupported {
+		t.Errorf("Expected ErrDirtyNotSupported, got %v", err)
+	}
+	if err := ClearDirty("fake://"); err != ErrDirtyNotSupported {
+		t.Errorf("Expected ErrDirtyNotSupported, got %v", err)
+	}
+
+	fakeDirty.reset()
+	if err := Up("fakedirty://", tmpdir); err == nil {
+		t.Fatal("Expected version 2 to fail")
+	}
+	dirty, version, err := DirtyState("fakedirty://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dirty || version != 2 {
+		t.Fatalf("Expected dirty version 2, got dirty=%v version %v", dirty, version)
+	}
+
+	if err := ClearDirty("fakedirty://"); err != nil {
+		t.Fatal(err)
+	}
+	dirty, version, err = DirtyState("fakedirty://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirty || version != 2 {
+		t.Errorf("Expected clean version 2, got dirty=%v version %v", dirty, version)
+	}
+}
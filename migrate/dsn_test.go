@@ -0,0 +1,37 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/chr4/migrate/driver"
+)
+
+func TestUpDSN(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE yolo (id INTEGER PRIMARY KEY);",
+		"001_migration1.down.sql": "DROP TABLE yolo;",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	dsn := driver.DSN{Scheme: "sqlite3", Database: path.Join(tmpdir, "test.db")}
+	if err := UpDSN(dsn, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	connection, err := sql.Open("sqlite3", path.Join(tmpdir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connection.Close()
+	var count int
+	if err := connection.QueryRow("SELECT count(*) FROM yolo").Scan(&count); err != nil {
+		t.Errorf("Expected table yolo to be created: %v", err)
+	}
+
+	if err := UpDSN(driver.DSN{Scheme: "unknown", Host: "localhost"}, tmpdir); err == nil {
+		t.Error("Expected unknown driver to fail")
+	}
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/chr4/migrate/file"
+)
+
+// ComputeEmbeddedManifest returns the manifest of all up migration files
+// with the given filename extension in path of fsys, e.g. an embed.FS.
+// Checksums are computed like the ones stored when migrating. It doesn't
+// need a database, so it can be used at init time or in a build step to
+// pin the expected migrations, see AssertManifest. If ext is empty,
+// the most common extension in path is used.
+func ComputeEmbeddedManifest(fsys fs.FS, path, ext string) (Manifest, error) {
+	if ext == "" {
+		var err error
+		if ext, err = file.DetectExtensionFS(fsys, path); err != nil {
+			return nil, err
+		}
+	}
+	files, err := file.ReadMigrationFilesFS(fsys, path, file.FilenameRegex(ext))
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(Manifest, 0, len(files))
+	for _, mf := range files {
+		if mf.UpFile == nil {
+			continue
+		}
+		f := mf.UpFile
+		if err := f.ReadContent(); err != nil {
+			return nil, err
+		}
+		m = append(m, ManifestEntry{Version: f.Version, Name: f.Name, Checksum: checksum(f.Content)})
+	}
+	return m, nil
+}
+
+// AssertManifest compares the migration files in path of fsys with
+// the expected manifest, as returned by ComputeEmbeddedManifest when
+// building the binary. It returns an error listing every version which
+// was added, removed or modified.
+func AssertManifest(fsys fs.FS, path, ext string, expected Manifest) error {
+	actual, err := ComputeEmbeddedManifest(fsys, path, ext)
+	if err != nil {
+		return err
+	}
+	diffs := compareManifests(expected, actual)
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	mismatches := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		if diff.Actual == nil {
+			mismatches = append(mismatches, fmt.Sprintf("version %v: missing in embedded migrations", diff.Version))
+			continue
+		}
+		mismatches = append(mismatches, diff.String())
+	}
+	return errors.New("embedded migrations don't match manifest:\n" + strings.Join(mismatches, "\n"))
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/chr4/migrate/file"
+)
+
+func TestEmbeddedManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_create_foo.up.sql":   {Data: []byte("CREATE TABLE foo ();")},
+		"migrations/001_create_foo.down.sql": {Data: []byte("DROP TABLE foo;")},
+		"migrations/002_create_bar.up.sql":   {Data: []byte("CREATE TABLE bar ();")},
+		"migrations/README.md":               {Data: []byte("not a migration")},
+	}
+
+	m, err := ComputeEmbeddedManifest(fsys, "migrations", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m[0].Version != 1 || m[0].Name != "create_foo" || m[1].Version != 2 {
+		t.Fatalf("Unexpected manifest %v", m)
+	}
+	if m[0].Checksum != checksum([]byte("CREATE TABLE foo ();")) {
+		t.Errorf("Unexpected checksum %v", m[0].Checksum)
+	}
+
+	if err := AssertManifest(fsys, "migrations", "sql", m); err != nil {
+		t.Fatal(err)
+	}
+	if detected, err := ComputeEmbeddedManifest(fsys, "migrations", ""); err != nil || len(detected) != len(m) {
+		t.Errorf("Expected the extension to be detected, got %v (%v)", detected, err)
+	}
+
+	// tamper with an embedded migration, remove one and add another
+	fsys["migrations/001_create_foo.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE foo (id int);")}
+	delete(fsys, "migrations/002_create_bar.up.sql")
+	fsys["migrations/003_create_baz.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE baz ();")}
+
+	err = AssertManifest(fsys, "migrations", "sql", m)
+	if err == nil {
+		t.Fatal("Expected tampered migrations to fail")
+	}
+	for _, expect := range []string{"version 1: checksum", "version 2: missing", "version 3: not in manifest"} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected error to contain %q, got %v", expect, err)
+		}
+	}
+}
+
+func TestReadMigrationFilesMap(t *testing.T) {
+	files, err := file.ReadMigrationFilesMap(map[string][]byte{
+		"001_create_foo.up.sql":   []byte("CREATE TABLE foo ();"),
+		"001_create_foo.down.sql": []byte("DROP TABLE foo;"),
+		"002_create_bar.up.sql":   []byte("CREATE TABLE bar ();"),
+	}, filenameRegex(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upFiles, err := pendingUpFiles(nil, &files, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.reset()
+	if err := migrateFiles(fake, upFiles); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+	if len(fake.applied) != 2 || string(fake.applied[1].Content) != "CREATE TABLE bar ();" {
+		t.Errorf("Expected content from the map to be applied, got %v", fake.applied)
+	}
+	if fake.checksums[1] != checksum([]byte("CREATE TABLE foo ();")) {
+		t.Errorf("Unexpected checksum %v", fake.checksums[1])
+	}
+}
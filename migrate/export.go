@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ExportCSV writes all applied migrations to w as CSV with the columns
+// version, name, applied_at and checksum, e.g. for reviewing changes in
+// a spreadsheet. Times are formatted as RFC 3339 in UTC. Columns the
+// driver doesn't store are left empty.
+func ExportCSV(url string, w io.Writer) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	vd, ok := d.(driver.VersionsDriver)
+	if !ok {
+		return ErrVersionsNotSupported
+	}
+	versions, err := vd.Versions()
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"version", "name", "applied_at", "checksum"}); err != nil {
+		return err
+	}
+	for _, v := range versions {
+		appliedAt := ""
+		if !v.AppliedAt.IsZero() {
+			appliedAt = v.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if err := cw.Write([]string{strconv.FormatUint(v.Version, 10), v.Name, appliedAt, v.Checksum}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
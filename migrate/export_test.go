@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExportCSV(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_create_users.up.sql":      "SELECT 1",
+		"002_add_email, unique.up.sql": "SELECT 2",
+		"003_migration3.up.sql":        "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+	appliedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	fake.appliedAt[1] = appliedAt
+	delete(fake.appliedAt, 2)
+
+	var buf bytes.Buffer
+	if err := ExportCSV("fake://", &buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := [][]string{
+		{"version", "name", "applied_at", "checksum"},
+		{"1", "create_users", "2020-01-02T02:04:05Z", checksum([]byte("SELECT 1"))},
+		{"2", "add_email, unique", "", checksum([]byte("SELECT 2"))},
+	}
+	if len(records) != len(expect) {
+		t.Fatalf("Expected %v records, got %v", len(expect), records)
+	}
+	for i := range expect {
+		for j := range expect[i] {
+			if records[i][j] != expect[i][j] {
+				t.Errorf("Expected %q in record %v column %v, got %q", expect[i][j], i, j, records[i][j])
+			}
+		}
+	}
+}
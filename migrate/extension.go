@@ -0,0 +1,24 @@
+package migrate
+
+import (
+	"github.com/chr4/migrate/driver"
+)
+
+// autoCreateExtensions is an internal variable that holds the
+// state of creating missing required extensions
+var autoCreateExtensions = false
+
+// AutoCreateExtensions makes drivers create the extensions required by a
+// "-- migrate:requires-extension pgcrypto" directive if they are missing,
+// instead of failing the migration before it runs. Creating extensions
+// usually requires elevated privileges. See driver.ExtensionCreator.
+func AutoCreateExtensions(enable bool) {
+	autoCreateExtensions = enable
+}
+
+// configureExtensions passes the auto-create setting to the driver.
+func configureExtensions(d driver.Driver) {
+	if ec, ok := d.(driver.ExtensionCreator); ok {
+		ec.SetAutoCreateExtensions(autoCreateExtensions)
+	}
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrObjectNotFound is returned by FindMigrationForObject if no
+// applied up migration creates the object.
+var ErrObjectNotFound = errors.New("no applied migration creates the object")
+
+// createObjectRegex returns a regex matching a CREATE statement of
+// objectName, e.g. "yolo" or "public.yolo". Names are matched case
+// insensitive, either quoted or not. An unqualified name also
+// matches objects created in any schema.
+func createObjectRegex(objectName string) *regexp.Regexp {
+	parts := strings.Split(objectName, ".")
+	for i, part := range parts {
+		parts[i] = `"?` + regexp.QuoteMeta(strings.Trim(part, `"`)) + `"?`
+	}
+	name := strings.Join(parts, `\.`)
+	if len(parts) == 1 {
+		name = `(?:(?:"[^"]+"|\w+)\.)?` + name
+	}
+	return regexp.MustCompile(`(?i)\bCREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:UNIQUE|MATERIALIZED|TEMP|TEMPORARY|UNLOGGED)\s+)*` +
+		`(?:TABLE|VIEW|INDEX|SEQUENCE|FUNCTION|PROCEDURE|TYPE|DOMAIN|SCHEMA|TRIGGER|EXTENSION)\s+` +
+		`(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + name + `(?:[\s(;]|$)`)
+}
+
+// FindMigrationForObject returns the first applied version whose up
+// migration file creates a table, view, index or other object named
+// objectName. It is a heuristic for debugging: it scans the content of
+// the files on disk, so objects created dynamically, e.g. in a DO block
+// or a Go migration, aren't found. It doesn't acquire the migration lock.
+func FindMigrationForObject(url, migrationsPath, objectName string) (uint64, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	vd, ok := d.(driver.VersionsDriver)
+	if !ok {
+		return 0, ErrVersionsNotSupported
+	}
+	applied, err := vd.Versions()
+	if err != nil {
+		return 0, err
+	}
+
+	files, err := readMigrationFiles(migrationsPath, filenameRegex(d))
+	if err != nil {
+		return 0, err
+	}
+
+	re := createObjectRegex(objectName)
+	for _, v := range applied {
+		mf := files.Find(v.Version)
+		if mf == nil || mf.UpFile == nil || mf.UpFile.GoFunc != nil {
+			continue
+		}
+		f := mf.UpFile
+		if err := f.ReadContent(); err != nil {
+			return 0, err
+		}
+		if re.Match(f.Content) {
+			return v.Version, nil
+		}
+	}
+	return 0, ErrObjectNotFound
+}
@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCreateObjectRegex(t *testing.T) {
+	var tests = []struct {
+		objectName string
+		content    string
+		expect     bool
+	}{
+		{"yolo", "CREATE TABLE yolo (id int);", true},
+		{"yolo", "create table if not exists public.yolo(id int);", true},
+		{"yolo", `CREATE TABLE "Yolo" (id int);`, true},
+		{"yolo", "CREATE TABLE yolo_archive (id int);", false},
+		{"yolo", "CREATE INDEX yolo_idx ON yolo (id);", false},
+		{"yolo", "ALTER TABLE yolo ADD COLUMN name text;", false},
+		{"yolo_idx", "CREATE UNIQUE INDEX CONCURRENTLY yolo_idx ON yolo (id);", true},
+		{"yolo_view", "CREATE OR REPLACE VIEW yolo_view AS SELECT 1;", true},
+		{"public.yolo", "CREATE TABLE public.yolo (id int);", true},
+		{"public.yolo", "CREATE TABLE other.yolo (id int);", false},
+	}
+
+	for _, test := range tests {
+		if match := createObjectRegex(test.objectName).MatchString(test.content); match != test.expect {
+			t.Errorf("createObjectRegex(%q) matching %q = %v, expected %v", test.objectName, test.content, match, test.expect)
+		}
+	}
+}
+
+func TestFindMigrationForObject(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE yolo (id int);",
+		"002_migration2.up.sql": "CREATE INDEX yolo_idx ON yolo (id); CREATE TABLE yolo_archive (id int);",
+		"003_migration3.up.sql": "CREATE TABLE pending (id int);",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for objectName, expect := range map[string]uint64{"yolo": 1, "yolo_idx": 2, "yolo_archive": 2} {
+		version, err := FindMigrationForObject("fake://", tmpdir, objectName)
+		if err != nil {
+			t.Errorf("FindMigrationForObject(%q) returned error %v", objectName, err)
+		} else if version != expect {
+			t.Errorf("FindMigrationForObject(%q) = %v, expected %v", objectName, version, expect)
+		}
+	}
+
+	// version 3 is not applied
+	if _, err := FindMigrationForObject("fake://", tmpdir, "pending"); err != ErrObjectNotFound {
+		t.Errorf("Expected ErrObjectNotFound, got %v", err)
+	}
+}
+
+func TestFindMigrationForObjectPostgres(t *testing.T) {
+	// use a dedicated database, other tests leave tables behind
+	server, err := sql.Open("postgres", strings.Replace(driverUrls[0], "/template1", "/postgres", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if _, err := server.Exec(`DROP DATABASE IF EXISTS migrate_test_find`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Exec(`CREATE DATABASE migrate_test_find`); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Exec(`DROP DATABASE IF EXISTS migrate_test_find`)
+	driverUrl := strings.Replace(driverUrls[0], "/template1", "/migrate_test_find", 1)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE yolo (id serial not null primary key);",
+		"002_migration2.up.sql": "CREATE TABLE public.yolo_archive (id int);",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	if err := Up(driverUrl, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := FindMigrationForObject(driverUrl, tmpdir, "public.yolo"); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+	if version, err := FindMigrationForObject(driverUrl, tmpdir, "yolo_archive"); err != nil || version != 2 {
+		t.Errorf("Expected version 2, got %v (%v)", version, err)
+	}
+}
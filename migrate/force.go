@@ -0,0 +1,34 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrForceDownNotSupported is returned if the driver doesn't
+// implement driver.ForceDownDriver.
+var ErrForceDownNotSupported = errors.New("driver does not support forcing versions down")
+
+// ForceDown removes all versions greater than version from the version
+// table without running any down migrations, e.g. after the database has
+// been rolled back manually. The database is left at version.
+func ForceDown(url string, version uint64) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+
+	fd, ok := d.(driver.ForceDownDriver)
+	if !ok {
+		d.Close()
+		return ErrForceDownNotSupported
+	}
+	if err := lock(d); err != nil {
+		d.Close()
+		return err
+	}
+	defer closeDriver(d)
+
+	return fd.ForceDown(version)
+}
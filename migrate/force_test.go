@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestForceDown(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+		"003_migration3.up.sql": "SELECT 1",
+		"004_migration4.up.sql": "SELECT 1",
+		"005_migration5.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeLock.reset()
+	if err := Up("fakelock://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	fakeLock.applied = nil
+
+	if err := ForceDown("fakelock://", 3); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeLock.Version(); version != 3 {
+		t.Errorf("Expected version 3, got %v", version)
+	}
+	if len(fakeLock.versions) != 3 {
+		t.Errorf("Expected 3 versions to remain, got %v", fakeLock.versions)
+	}
+	if len(fakeLock.applied) != 0 {
+		t.Errorf("Expected no migrations to run, got %v", fakeLock.applied)
+	}
+
+	// pending versions are applied again by Up
+	if err := Up("fakelock://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeLock.applied) != 2 {
+		t.Errorf("Expected versions 4 and 5 to be applied again, got %v", fakeLock.applied)
+	}
+
+	if fakeLock.locked {
+		t.Error("Expected lock to be released")
+	}
+
+	if err := ForceDown("fake://", 3); err != ErrForceDownNotSupported {
+		t.Errorf("Expected ErrForceDownNotSupported, got %v", err)
+	}
+}
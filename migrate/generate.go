@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// generateTableName is the version table of the statements
+// written by GenerateSQL, the one of the postgres driver
+const generateTableName = "schema_migrations"
+
+// GenerateSQL writes a SQL script to w migrating from fromVersion to
+// toVersion, e.g. for DBAs applying changes with their own tooling. It
+// contains the content of the up migrations after fromVersion up to
+// toVersion, or of the down migrations after toVersion down to
+// fromVersion in reverse order if toVersion is lower. Each file is
+// followed by the statement recording its version in the version table
+// of the postgres driver. No database connection is made, Go migrations
+// can't be rendered. The script has no transaction control, wrap it as
+// required.
+func GenerateSQL(migrationsPath string, fromVersion, toVersion uint64, w io.Writer) error {
+	files, err := readMigrationFiles(migrationsPath, file.FilenameRegex("sql"))
+	if err != nil {
+		return err
+	}
+
+	scriptFiles := make(file.Files, 0)
+	if toVersion >= fromVersion {
+		sort.Sort(files)
+		for _, mf := range files {
+			if mf.Version > fromVersion && mf.Version <= toVersion && mf.UpFile != nil {
+				scriptFiles = append(scriptFiles, *mf.UpFile)
+			}
+		}
+	} else {
+		sort.Sort(sort.Reverse(files))
+		for _, mf := range files {
+			if mf.Version > toVersion && mf.Version <= fromVersion {
+				f, err := downFile(&files, mf.Version)
+				if err != nil {
+					return err
+				}
+				scriptFiles = append(scriptFiles, *f)
+			}
+		}
+	}
+
+	for _, f := range scriptFiles {
+		if f.GoFunc != nil {
+			return fmt.Errorf("%s is a Go migration and can't be rendered as SQL", f.FileName)
+		}
+		if f.VersionOnly {
+			if _, err := fmt.Fprintf(w, "-- %s\n%s\n\n", f.FileName, recordVersionSQL(f)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f.ReadContent(); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "-- %s\n%s\n%s\n\n", f.FileName, terminatedContent(f.Content), recordVersionSQL(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// terminatedContent trims content and makes sure its
+// last statement is terminated by a semicolon
+func terminatedContent(content []byte) string {
+	trimmed := string(bytes.TrimSpace(content))
+	if !strings.HasSuffix(trimmed, ";") {
+		trimmed += "\n;"
+	}
+	return trimmed
+}
+
+// recordVersionSQL returns the statement recording the version of f
+// like the postgres driver does when migrating f
+func recordVersionSQL(f file.File) string {
+	if f.Direction == direction.Down {
+		return fmt.Sprintf("DELETE FROM %s WHERE version = %d;", generateTableName, f.Version)
+	}
+	columns := "version, name, checksum, applied_at"
+	values := fmt.Sprintf("%d, %s, %s, now()", f.Version, quoteSQL(f.Name), quoteSQL(checksum(f.Content)))
+	if f.VersionString != "" {
+		columns += ", version_string"
+		values += ", " + quoteSQL(f.VersionString)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", generateTableName, columns, values)
+}
+
+// quoteSQL quotes s as SQL string literal
+func quoteSQL(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSQL(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE t1 ();",
+		"001_migration1.down.sql": "DROP TABLE t1;",
+		"002_migration2.up.sql":   "CREATE TABLE t2 ();\n",
+		"002_migration2.down.sql": "DROP TABLE t2;",
+		"003_it's.up.sql":         "INSERT INTO t2 DEFAULT VALUES",
+		"003_it's.down.sql":       "DELETE FROM t2;",
+		"004_migration4.up.sql":   "CREATE TABLE t4 ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var buf bytes.Buffer
+	if err := GenerateSQL(tmpdir, 1, 3, &buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := "-- 002_migration2.up.sql\n" +
+		"CREATE TABLE t2 ();\n" +
+		"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (2, 'migration2', '" + checksum([]byte("CREATE TABLE t2 ();\n")) + "', now());\n" +
+		"\n" +
+		"-- 003_it's.up.sql\n" +
+		"INSERT INTO t2 DEFAULT VALUES\n;\n" +
+		"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (3, 'it''s', '" + checksum([]byte("INSERT INTO t2 DEFAULT VALUES")) + "', now());\n" +
+		"\n"
+	if buf.String() != expect {
+		t.Errorf("Expected script\n%s\ngot\n%s", expect, buf.String())
+	}
+
+	buf.Reset()
+	if err := GenerateSQL(tmpdir, 3, 1, &buf); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	var last int
+	for _, expect := range []string{
+		"DELETE FROM t2;",
+		"DELETE FROM schema_migrations WHERE version = 3;",
+		"DROP TABLE t2;",
+		"DELETE FROM schema_migrations WHERE version = 2;",
+	} {
+		i := strings.Index(script, expect)
+		if i < last {
+			t.Errorf("Expected %q in order in script\n%s", expect, script)
+		}
+		last = i
+	}
+	if strings.Contains(script, "t1") {
+		t.Errorf("Expected version 1 not to be rolled back, got\n%s", script)
+	}
+
+	// version 4 has no down migration
+	if err := GenerateSQL(tmpdir, 4, 0, &buf); err == nil || !strings.Contains(err.Error(), "no down migration for version 4") {
+		t.Errorf("Expected error for missing down migration, got %v", err)
+	}
+	SkipMissingDown(true)
+	defer SkipMissingDown(false)
+	buf.Reset()
+	if err := GenerateSQL(tmpdir, 4, 3, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "-- 4 (no down migration)\nDELETE FROM schema_migrations WHERE version = 4;\n\n"; buf.String() != expect {
+		t.Errorf("Expected only the version to be removed, got %q", buf.String())
+	}
+}
@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/chr4/migrate/file"
+)
+
+// addedFiles returns the paths of files added since gitRef, relative to
+// dir. It is a variable, so tests can replace git.
+var addedFiles = gitAddedFiles
+
+// gitAddedFiles runs git diff in dir to list files added since gitRef.
+// Files need to be committed or staged, untracked files aren't listed.
+func gitAddedFiles(dir, gitRef string) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=A", "--relative", gitRef, "--", ".")
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff %s failed: %v: %s", gitRef, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// UpSince applies the up migrations added since gitRef, e.g. only those
+// introduced by a pull request in CI. The added migrations must directly
+// follow the current version: it fails if one of them is already applied
+// or if another pending migration would have to run before them.
+func UpSince(url, migrationsPath, gitRef string) (err error) {
+	added, err := addedFiles(migrationsPath, gitRef)
+	if err != nil {
+		return
+	}
+	isAdded := make(map[string]bool, len(added))
+	for _, name := range added {
+		isAdded[filepath.ToSlash(filepath.Clean(name))] = true
+	}
+
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return
+	}
+	defer closeDriver(d)
+
+	addedUpFiles := make(file.Files, 0)
+	for _, mf := range *files {
+		f := mf.UpFile
+		if f == nil || f.GoFunc != nil {
+			continue
+		}
+		rel, err := filepath.Rel(migrationsPath, filepath.Join(f.Path, f.FileName))
+		if err != nil || !isAdded[filepath.ToSlash(rel)] {
+			continue
+		}
+		if f.Version <= version {
+			return fmt.Errorf("%s was added since %s, but version %v is already applied", f.FileName, gitRef, version)
+		}
+		addedUpFiles = append(addedUpFiles, *f)
+	}
+
+	pending, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return
+	}
+	applyMigrationFiles := make(file.Files, 0, len(addedUpFiles))
+	for _, f := range pending {
+		if len(applyMigrationFiles) == len(addedUpFiles) {
+			break
+		}
+		if !containsVersion(addedUpFiles, f.Version) {
+			return fmt.Errorf("%s is pending, but wasn't added since %s", f.FileName, gitRef)
+		}
+		applyMigrationFiles = append(applyMigrationFiles, f)
+	}
+
+	err = migrateFiles(d, applyMigrationFiles)
+	return
+}
+
+// containsVersion reports whether files include version
+func containsVersion(files file.Files, version uint64) bool {
+	for _, f := range files {
+		if f.Version == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// mockAddedFiles makes UpSince see files as added since any ref
+func mockAddedFiles(t *testing.T, files ...string) func() {
+	addedFiles = func(dir, gitRef string) ([]string, error) {
+		if gitRef != "origin/main" {
+			t.Errorf("Expected ref origin/main, got %v", gitRef)
+		}
+		return files, nil
+	}
+	return func() { addedFiles = gitAddedFiles }
+}
+
+func TestUpSince(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+		"003_migration3.up.sql":   "SELECT 3",
+		"003_migration3.down.sql": "SELECT 3",
+		"004_migration4.up.sql":   "SELECT 4",
+		"005_migration5.up.sql":   "SELECT 5",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+	fake.applied = nil
+
+	defer mockAddedFiles(t, "003_migration3.up.sql", "003_migration3.down.sql", "004_migration4.up.sql", "README.md")()
+	if err := UpSince("fake://", tmpdir, "origin/main"); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 4 {
+		t.Errorf("Expected version 4, got %v", version)
+	}
+	if len(fake.applied) != 2 || fake.applied[0].Version != 3 || fake.applied[1].Version != 4 {
+		t.Errorf("Expected versions 3 and 4 to be applied, got %v", fake.applied)
+	}
+}
+
+func TestUpSinceNotContiguous(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 2",
+		"003_migration3.up.sql": "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// version 2 is pending, but not part of the change
+	defer mockAddedFiles(t, "003_migration3.up.sql")()
+	if err := UpSince("fake://", tmpdir, "origin/main"); err == nil || !strings.Contains(err.Error(), "002_migration2.up.sql is pending") {
+		t.Errorf("Expected error about pending version 2, got %v", err)
+	}
+
+	// version 1 is already applied
+	mockAddedFiles(t, "001_migration1.up.sql", "002_migration2.up.sql")
+	if err := UpSince("fake://", tmpdir, "origin/main"); err == nil || !strings.Contains(err.Error(), "already applied") {
+		t.Errorf("Expected error about applied version 1, got %v", err)
+	}
+	if version, _ := fake.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+}
+
+func TestUpSinceGitError(t *testing.T) {
+	addedFiles = func(dir, gitRef string) ([]string, error) {
+		return nil, errors.New("unknown revision")
+	}
+	defer func() { addedFiles = gitAddedFiles }()
+
+	fake.reset()
+	if err := UpSince("fake://", "/tmp", "nope"); err == nil || err.Error() != "unknown revision" {
+		t.Errorf("Expected git error, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// GoMigrationFunc is a migration written in Go, see RegisterGoMigration.
+type GoMigrationFunc = file.GoMigrationFunc
+
+// ErrGoMigrationNotSupported is returned if Go migrations are
+// registered, but the driver can't run them, see driver.GoMigrator.
+var ErrGoMigrationNotSupported = errors.New("driver does not support Go migrations")
+
+var (
+	goMigrationsMu sync.Mutex
+	goMigrations   = make(map[uint64]file.MigrationFile)
+)
+
+// RegisterGoMigration registers a migration written in Go, e.g. for
+// logic which is awkward in SQL like re-encrypting a column. It is
+// migrated along with the migration files in order of its version, and
+// up or down runs in the same transaction as the version bookkeeping.
+// down may be nil for irreversible migrations. Registering a version
+// twice panics.
+func RegisterGoMigration(version uint64, name string, up, down GoMigrationFunc) {
+	goMigrationsMu.Lock()
+	defer goMigrationsMu.Unlock()
+	if up == nil {
+		panic("migrate: RegisterGoMigration up is nil")
+	}
+	if _, dup := goMigrations[version]; dup {
+		panic(fmt.Sprintf("migrate: RegisterGoMigration called twice for version %v", version))
+	}
+
+	mf := file.MigrationFile{Version: version}
+	mf.UpFile = goMigrationFile(version, name, direction.Up, up)
+	if down != nil {
+		mf.DownFile = goMigrationFile(version, name, direction.Down, down)
+	}
+	goMigrations[version] = mf
+}
+
+// goMigrationFile returns the file a Go migration is handed to the
+// driver as. Its file name is used in messages only.
+func goMigrationFile(version uint64, name string, d direction.Direction, fn GoMigrationFunc) *file.File {
+	suffix := "up"
+	if d == direction.Down {
+		suffix = "down"
+	}
+	return &file.File{
+		FileName:  fmt.Sprintf("%d_%s.%s.go", version, name, suffix),
+		Version:   version,
+		Name:      name,
+		Direction: d,
+		GoFunc:    fn,
+	}
+}
+
+// addGoMigrations adds the registered Go migrations to files.
+// Versions must be unique across files and Go migrations.
+func addGoMigrations(files file.MigrationFiles) (file.MigrationFiles, error) {
+	goMigrationsMu.Lock()
+	defer goMigrationsMu.Unlock()
+	if len(goMigrations) == 0 {
+		return files, nil
+	}
+	for version, mf := range goMigrations {
+		if files.Find(version) != nil {
+			return nil, fmt.Errorf("version %v is registered as Go migration and exists as file", version)
+		}
+		files = append(files, mf)
+	}
+	sort.Sort(files)
+	return files, nil
+}
+
+// checkGoMigrations returns ErrGoMigrationNotSupported if
+// Go migrations are registered, but d can't run them.
+func checkGoMigrations(d driver.Driver) error {
+	goMigrationsMu.Lock()
+	defer goMigrationsMu.Unlock()
+	if _, ok := d.(driver.GoMigrator); !ok && len(goMigrations) > 0 {
+		return ErrGoMigrationNotSupported
+	}
+	return nil
+}
+
+// migrateGo hands a Go migration to the driver
+func migrateGo(d driver.Driver, f file.File) error {
+	gm, ok := d.(driver.GoMigrator)
+	if !ok {
+		return ErrGoMigrationNotSupported
+	}
+	return gm.MigrateGo(f)
+}
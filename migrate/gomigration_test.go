@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+)
+
+// resetGoMigrations removes all registered Go migrations
+func resetGoMigrations() {
+	goMigrationsMu.Lock()
+	defer goMigrationsMu.Unlock()
+	goMigrations = make(map[uint64]file.MigrationFile)
+}
+
+// updateSecrets returns a Go migration applying fn to all secrets
+// in table yolo, which fails after updating if fail is true.
+func updateSecrets(fn func(string) string, fail *bool) GoMigrationFunc {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT id, secret FROM yolo")
+		if err != nil {
+			return err
+		}
+		secrets := make(map[int]string)
+		for rows.Next() {
+			var id int
+			var secret string
+			if err := rows.Scan(&id, &secret); err != nil {
+				rows.Close()
+				return err
+			}
+			secrets[id] = secret
+		}
+		rows.Close()
+		for id, secret := range secrets {
+			if _, err := tx.ExecContext(ctx, "UPDATE yolo SET secret=? WHERE id=?", fn(secret), id); err != nil {
+				return err
+			}
+		}
+		if fail != nil && *fail {
+			return errors.New("key unavailable")
+		}
+		return nil
+	}
+}
+
+func TestGoMigration(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+	defer resetGoMigrations()
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE yolo (id INTEGER PRIMARY KEY, secret TEXT); INSERT INTO yolo (secret) VALUES ('a'), ('b');",
+		"001_migration1.down.sql": "DROP TABLE yolo;",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+	driverUrl := "sqlite3://" + path.Join(tmpdir, "test.db")
+
+	fail := true
+	RegisterGoMigration(2, "reencrypt", updateSecrets(strings.ToUpper, &fail), updateSecrets(strings.ToLower, nil))
+
+	secrets := func() string {
+		connection, err := sql.Open("sqlite3", path.Join(tmpdir, "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer connection.Close()
+		var secrets string
+		if err := connection.QueryRow("SELECT group_concat(secret, '') FROM yolo").Scan(&secrets); err != nil {
+			t.Fatal(err)
+		}
+		return secrets
+	}
+
+	// neither the updated rows nor the version are committed
+	if err := Up(driverUrl, tmpdir); err == nil || !strings.Contains(err.Error(), "2_reencrypt.up.go: key unavailable") {
+		t.Errorf("Expected error of the Go migration, got %v", err)
+	}
+	if version, err := Version(driverUrl, tmpdir); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+	if s := secrets(); s != "ab" {
+		t.Errorf("Expected failed Go migration to be rolled back, got %q", s)
+	}
+
+	fail = false
+	if err := Up(driverUrl, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := Version(driverUrl, tmpdir); err != nil || version != 3 {
+		t.Errorf("Expected version 3, got %v (%v)", version, err)
+	}
+	if s := secrets(); s != "AB" {
+		t.Errorf("Expected secrets to be updated, got %q", s)
+	}
+
+	if err := Migrate(driverUrl, tmpdir, -2); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := Version(driverUrl, tmpdir); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %v (%v)", version, err)
+	}
+	if s := secrets(); s != "ab" {
+		t.Errorf("Expected secrets to be rolled back, got %q", s)
+	}
+}
+
+func TestGoMigrationNotSupported(t *testing.T) {
+	defer resetGoMigrations()
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	RegisterGoMigration(2, "noop", updateSecrets(strings.ToUpper, nil), nil)
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != ErrGoMigrationNotSupported {
+		t.Errorf("Expected ErrGoMigrationNotSupported, got %v", err)
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected no migrations to run, got %v", fake.applied)
+	}
+}
+
+func TestGoMigrationDuplicateVersion(t *testing.T) {
+	defer resetGoMigrations()
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	RegisterGoMigration(1, "noop", updateSecrets(strings.ToUpper, nil), nil)
+	if err := Up("sqlite3://"+path.Join(tmpdir, "test.db"), tmpdir); err == nil || !strings.Contains(err.Error(), "registered as Go migration") {
+		t.Errorf("Expected error about duplicate version, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a version twice to panic")
+		}
+	}()
+	RegisterGoMigration(1, "noop", updateSecrets(strings.ToUpper, nil), nil)
+}
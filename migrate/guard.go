@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// recordGuardedVersions is an internal variable that holds the
+// state of recording versions skipped by their guard
+var recordGuardedVersions = true
+
+// RecordGuardedVersions sets whether the version of a migration skipped
+// by its guard is still recorded, which is the default. Otherwise it
+// stays unrecorded, even though later versions are applied.
+func RecordGuardedVersions(enable bool) {
+	recordGuardedVersions = enable
+}
+
+// guardedContent replaces the content of a migration skipped by its guard
+var guardedContent = []byte("-- skipped, guard returned false\n")
+
+// evaluateGuard runs the query of a "-- migrate:guard SELECT ..." directive
+// in f and reports whether f may run. A guard permits the migration if it
+// returns a row, unless its first column is false. It requires
+// driver.QueryDriver.
+func evaluateGuard(d driver.Driver, f file.File) (bool, error) {
+	query, ok := f.Directive("guard")
+	if !ok {
+		return true, nil
+	}
+	if query == "" {
+		return false, fmt.Errorf("%s: guard directive requires a query", f.FileName)
+	}
+	qd, ok := d.(driver.QueryDriver)
+	if !ok {
+		return false, ErrQueryNotSupported
+	}
+
+	rows, err := qd.QueryRows(query, 1)
+	if err != nil {
+		return false, fmt.Errorf("%s: guard failed: %v", f.FileName, err)
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return false, nil
+	}
+	if rows[0][0] == "" {
+		// NULL
+		return false, nil
+	}
+	if permit, err := strconv.ParseBool(rows[0][0]); err == nil {
+		return permit, nil
+	}
+	return true, nil
+}
+
+// applyGuard evaluates the guard of f and replaces its content if the
+// guard doesn't permit it to run. skip is true if f must not be handed
+// to the driver at all, see RecordGuardedVersions.
+func applyGuard(d driver.Driver, f *file.File) (skip bool, err error) {
+	permit, err := evaluateGuard(d, *f)
+	if err != nil || permit {
+		return false, err
+	}
+	logger.Printf("warning: %s skipped, guard returned false", f.FileName)
+	if !recordGuardedVersions {
+		return true, nil
+	}
+	f.Content = guardedContent
+	return false, nil
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	guard := "SELECT enabled FROM feature_flags WHERE name = 'billing'"
+	guarded := "-- migrate:guard " + guard + "\nUPDATE billing SET migrated = true;"
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": guarded,
+		"003_migration3.up.sql": "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	contents := func() []string {
+		contents := make([]string, 0)
+		for _, f := range fakeQuery.applied {
+			contents = append(contents, string(f.Content))
+		}
+		return contents
+	}
+
+	// passing guard
+	fakeQuery.reset()
+	fakeQuery.rows = [][]string{{"true"}}
+	if err := Up("fakequery://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeQuery.queries) != 1 || fakeQuery.queries[0] != guard {
+		t.Errorf("Expected guard to run once, got %v", fakeQuery.queries)
+	}
+	if c := contents(); len(c) != 3 || !strings.Contains(c[1], "UPDATE billing") {
+		t.Errorf("Expected guarded migration to run, got %v", c)
+	}
+
+	// failing guard, the version is recorded nonetheless
+	for _, rows := range [][][]string{{{"false"}}, {{"f"}}, {{""}}, nil} {
+		fakeQuery.reset()
+		fakeQuery.rows = rows
+		if err := Up("fakequery://", tmpdir); err != nil {
+			t.Fatal(err)
+		}
+		if c := contents(); len(c) != 3 || strings.Contains(c[1], "UPDATE billing") {
+			t.Errorf("Expected guarded migration to be skipped with rows %v, got %v", rows, c)
+		}
+		if !fakeQuery.versions[2] {
+			t.Errorf("Expected version 2 to be recorded with rows %v", rows)
+		}
+		if fakeQuery.checksums[2] != checksum([]byte(guarded)) {
+			t.Errorf("Expected checksum of the original content, got %v", fakeQuery.checksums[2])
+		}
+	}
+
+	// failing guard without recording the version
+	RecordGuardedVersions(false)
+	defer RecordGuardedVersions(true)
+	fakeQuery.reset()
+	fakeQuery.rows = [][]string{{"false"}}
+	if err := Up("fakequery://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeQuery.applied) != 2 || fakeQuery.versions[2] {
+		t.Errorf("Expected version 2 to be skipped without recording, got %v", contents())
+	}
+
+	// drivers without queries
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != ErrQueryNotSupported {
+		t.Errorf("Expected ErrQueryNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"regexp"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// The functions in this file take a driver instead of a url, e.g. one
+// working on the connection pool of an application, see
+// postgres.WithDB, generic.WithDB and driver.NewWithDB. Initialize
+// isn't called and d is closed afterwards like the drivers created
+// from urls; drivers working on an existing *sql.DB leave it open.
+
+// UpWithDriver applies all available migrations like Up using d.
+func UpWithDriver(d driver.Driver, migrationsPath string) error {
+	return upWithDriver(d, migrationsPath, filenameRegex(d))
+}
+
+// upWithDriver implements UpWithDriver and UpWithDB
+// for files matching filenameRegex
+func upWithDriver(d driver.Driver, migrationsPath string, filenameRegex *regexp.Regexp) error {
+	_, err := runUp(d, migrationsPath, filenameRegex)
+	return err
+}
+
+// DownWithDriver rolls back all migrations like Down using d.
+// It requires AllowDown.
+func DownWithDriver(d driver.Driver, migrationsPath string) error {
+	if !allowDown {
+		d.Close()
+		return ErrDownNotAllowed
+	}
+	files, version, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex(d))
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	applyMigrationFiles, err := allDownFiles(files, version)
+	if err != nil {
+		return err
+	}
+	return migrateFiles(d, applyMigrationFiles)
+}
+
+// MigrateWithDriver applies relative +n/-n migrations like
+// Migrate using d.
+func MigrateWithDriver(d driver.Driver, migrationsPath string, relativeN int) error {
+	files, version, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex(d))
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	applyMigrationFiles, err := files.From(version, relativeN)
+	if err != nil {
+		return err
+	}
+	if relativeN == 0 {
+		return nil
+	}
+	return migrateFiles(d, applyMigrationFiles)
+}
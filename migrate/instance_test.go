@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWithDriver(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	// not registered, Initialize is never called
+	d := &fakeDriver{}
+	d.reset()
+
+	if err := MigrateWithDriver(d, tmpdir, +1); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := d.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	if err := UpWithDriver(d, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := d.Version(); version != 3 {
+		t.Errorf("Expected version 3, got %v", version)
+	}
+
+	if err := DownWithDriver(d, tmpdir); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed, got %v", err)
+	}
+	AllowDown(true)
+	defer AllowDown(false)
+	if err := DownWithDriver(d, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := d.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+	if len(d.applied) != 6 {
+		t.Errorf("Expected 6 migrations to run, got %v", len(d.applied))
+	}
+}
+
+func TestUpWithDriverAutoRollback(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	AutoRollback(true)
+	defer AutoRollback(false)
+
+	d := &fakeDriver{}
+	d.reset()
+	if err := UpWithDriver(d, tmpdir); err == nil {
+		t.Fatal("Expected version 2 to fail")
+	}
+	if version, _ := d.Version(); version != 0 {
+		t.Errorf("Expected version 0 after rollback, got %v", version)
+	}
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/chr4/migrate/file"
+)
+
+// InverseRule turns an up statement matching Pattern into a down
+// statement. Replacement may refer to submatches like $1, see
+// regexp.Regexp.Expand. An empty Replacement marks statements which
+// can't be reverted automatically, they're listed as TODO comments.
+type InverseRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// DefaultInverseRules revert common DDL statements. Users can append
+// domain specific rules, see SetInverseRules.
+var DefaultInverseRules = []InverseRule{
+	{regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`), "DROP TABLE $1"},
+	{regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?VIEW\s+([\w."]+)`), "DROP VIEW $1"},
+	{regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`), "DROP INDEX $1"},
+	{regexp.MustCompile(`(?is)^CREATE\s+SEQUENCE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`), "DROP SEQUENCE $1"},
+	{regexp.MustCompile(`(?is)^CREATE\s+SCHEMA\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`), "DROP SCHEMA $1"},
+	{regexp.MustCompile(`(?is)^CREATE\s+EXTENSION\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`), "DROP EXTENSION $1"},
+	{regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+([\w."]+)\s+ADD\s+CONSTRAINT\s+([\w"]+)`), "ALTER TABLE $1 DROP CONSTRAINT $2"},
+	// unnamed constraints, which the ADD COLUMN rule would mistake for columns
+	{regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+[\w."]+\s+ADD\s+(?:PRIMARY|UNIQUE|CHECK|FOREIGN|CONSTRAINT)\b`), ""},
+	{regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+([\w."]+)\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w"]+)`), "ALTER TABLE $1 DROP COLUMN $2"},
+	// the schema stays with the table, RENAME TO takes the bare name
+	{regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+((?:[\w"]+\.)?)([\w"]+)\s+RENAME\s+TO\s+([\w"]+)`), "ALTER TABLE $1$3 RENAME TO $2"},
+	{regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+([\w."]+)\s+RENAME\s+(?:COLUMN\s+)?([\w"]+)\s+TO\s+([\w"]+)`), "ALTER TABLE $1 RENAME COLUMN $3 TO $2"},
+}
+
+// inverseRules is an internal variable that holds the
+// rules used by GenerateDown
+var inverseRules = DefaultInverseRules
+
+// SetInverseRules sets the rules used by GenerateDown and
+// CreateReversible. The first matching rule is applied to a statement.
+// Pass nil to restore DefaultInverseRules.
+func SetInverseRules(rules []InverseRule) {
+	if rules == nil {
+		rules = DefaultInverseRules
+	}
+	inverseRules = rules
+}
+
+// GenerateDown returns down content reverting the statements of up in
+// reverse order. Statements without a matching rule are listed as TODO
+// comments, to be reverted manually.
+func GenerateDown(up []byte) []byte {
+	statements := file.SplitStatements(up)
+	var buf bytes.Buffer
+	for i := len(statements) - 1; i >= 0; i-- {
+		stmt := strings.TrimSpace(string(statements[i]))
+		if stmt == "" {
+			continue
+		}
+		buf.WriteString(inverseStatement(stmt))
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// inverseStatement applies the first matching rule to stmt
+func inverseStatement(stmt string) string {
+	for _, rule := range inverseRules {
+		m := rule.Pattern.FindStringSubmatchIndex(stmt)
+		if m == nil {
+			continue
+		}
+		if rule.Replacement == "" {
+			break
+		}
+		return string(rule.Pattern.ExpandString(nil, rule.Replacement, stmt, m)) + ";"
+	}
+	return "-- TODO revert: " + strings.Join(strings.Fields(stmt), " ")
+}
+
+// CreateReversible creates new migration files on disk like Create,
+// with up as content of the up file and the down file's content
+// generated from it, see GenerateDown.
+func CreateReversible(url, migrationsPath, name string, up []byte) (*file.MigrationFile, error) {
+	mfile, err := Create(url, migrationsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	mfile.UpFile.Content = up
+	mfile.DownFile.Content = GenerateDown(up)
+
+	if err := ioutil.WriteFile(path.Join(mfile.UpFile.Path, mfile.UpFile.FileName), mfile.UpFile.Content, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path.Join(mfile.DownFile.Path, mfile.DownFile.FileName), mfile.DownFile.Content, 0644); err != nil {
+		return nil, err
+	}
+	return mfile, nil
+}
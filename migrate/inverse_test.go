@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateDown(t *testing.T) {
+	up := []byte(`
+		CREATE TABLE IF NOT EXISTS users (id serial primary key);
+		ALTER TABLE users ADD COLUMN email text;
+		ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+		CREATE UNIQUE INDEX CONCURRENTLY users_email_idx ON users (email);
+		ALTER TABLE users RENAME COLUMN email TO mail;
+		UPDATE users SET mail = 'a;b';
+		ALTER TABLE users ADD PRIMARY KEY (id);
+		ALTER TABLE users ADD UNIQUE (mail);
+		ALTER TABLE users ADD CHECK (id > 0);
+		ALTER TABLE users ADD FOREIGN KEY (id) REFERENCES people (id);
+		ALTER TABLE public.users RENAME TO people;`)
+	expect := `ALTER TABLE public.people RENAME TO users;
+-- TODO revert: ALTER TABLE users ADD FOREIGN KEY (id) REFERENCES people (id)
+-- TODO revert: ALTER TABLE users ADD CHECK (id > 0)
+-- TODO revert: ALTER TABLE users ADD UNIQUE (mail)
+-- TODO revert: ALTER TABLE users ADD PRIMARY KEY (id)
+-- TODO revert: UPDATE users SET mail = 'a;b'
+ALTER TABLE users RENAME COLUMN mail TO email;
+DROP INDEX users_email_idx;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users DROP COLUMN email;
+DROP TABLE users;
+`
+	if down := string(GenerateDown(up)); down != expect {
+		t.Errorf("Expected down content:\n%s\ngot:\n%s", expect, down)
+	}
+}
+
+func TestSetInverseRules(t *testing.T) {
+	rules := append([]InverseRule{
+		{regexp.MustCompile(`(?is)^SELECT\s+create_hypertable\('(\w+)'`), "SELECT drop_chunks('$1', older_than => 0)"},
+	}, DefaultInverseRules...)
+	SetInverseRules(rules)
+	defer SetInverseRules(nil)
+
+	tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	up := []byte("CREATE TABLE metrics (time timestamptz);\nSELECT create_hypertable('metrics', 'time');\n")
+	mfile, err := CreateReversible("fake://", tmpdir, "add metrics", up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mfile.UpFile.FileName != "0001_add_metrics.up.sql" {
+		t.Errorf("Expected up file 0001_add_metrics.up.sql, got %v", mfile.UpFile.FileName)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tmpdir, mfile.UpFile.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(up) {
+		t.Errorf("Expected up content %q, got %q", up, content)
+	}
+	content, err = ioutil.ReadFile(path.Join(tmpdir, mfile.DownFile.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT drop_chunks('metrics', older_than => 0);\nDROP TABLE metrics;\n"
+	if string(content) != expect {
+		t.Errorf("Expected down content %q, got %q", expect, content)
+	}
+
+	// restoring the defaults drops the custom rule
+	SetInverseRules(nil)
+	if down := string(GenerateDown(up)); down != "-- TODO revert: SELECT create_hypertable('metrics', 'time')\nDROP TABLE metrics;\n" {
+		t.Errorf("Expected custom rule to be removed, got %q", down)
+	}
+}
@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.json":   `{"collection": "users"}`,
+		"001_migration1.down.json": `{"collection": "users"}`,
+		"002_migration2.up.json":   `{"collection": "orders"}`,
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeJSON.reset()
+	if err := Up("fakejson://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeJSON.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+
+	// nothing is applied if any file is invalid
+	tmpdir2 := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.json": `{"collection": "users"}`,
+		"002_migration2.up.json": `{"collection": 2}`,
+	})
+	defer os.RemoveAll(tmpdir2)
+
+	fakeJSON.reset()
+	err := Up("fakejson://", tmpdir2)
+	if err == nil || !strings.Contains(err.Error(), "002_migration2.up.json: $.collection: expected string, got integer") {
+		t.Errorf("Expected invalid file and field in error, got %v", err)
+	}
+	if len(fakeJSON.applied) != 0 {
+		t.Errorf("Expected no migrations to run, got %v", fakeJSON.applied)
+	}
+}
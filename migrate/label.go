@@ -0,0 +1,13 @@
+package migrate
+
+// runLabel is an internal variable that holds the label of the run
+var runLabel string
+
+// SetRunLabel sets a label like a git SHA or deploy id, which drivers
+// store along with the versions applied by Up, so the version table
+// tells which deploy applied which migration. The label is returned by
+// drivers implementing driver.VersionsDriver in AppliedMigration.RunLabel.
+// Pass an empty label to remove it.
+func SetRunLabel(label string) {
+	runLabel = label
+}
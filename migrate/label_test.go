@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetRunLabel(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	SetRunLabel("deploy-abc123")
+	defer SetRunLabel("")
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate("fake://", tmpdir, -1); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 2 || fake.applied[0].RunLabel != "deploy-abc123" || fake.applied[1].RunLabel != "" {
+		t.Errorf("Expected the label to be passed with up migrations only, got %v", fake.applied)
+	}
+
+	SetRunLabel("")
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fake.applied {
+		if f.RunLabel != "" {
+			t.Errorf("Expected no label, got %q", f.RunLabel)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ManifestEntry describes one applied migration in a Manifest.
+type ManifestEntry struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	Checksum  string     `json:"checksum"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Manifest is a snapshot of applied migrations, sorted by version.
+type Manifest []ManifestEntry
+
+// Difference describes a version which differs between a
+// manifest and a database.
+type Difference struct {
+	Version uint64
+
+	// Expected is the manifest's entry, nil if missing in the manifest
+	Expected *ManifestEntry
+
+	// Actual is the database's entry, nil if missing in the database
+	Actual *ManifestEntry
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Actual == nil:
+		return fmt.Sprintf("version %v: not applied to database", d.Version)
+	case d.Expected == nil:
+		return fmt.Sprintf("version %v: not in manifest", d.Version)
+	case d.Expected.Checksum != d.Actual.Checksum:
+		return fmt.Sprintf("version %v: checksum %s, expected %s", d.Version, d.Actual.Checksum, d.Expected.Checksum)
+	default:
+		return fmt.Sprintf("version %v: name %s, expected %s", d.Version, d.Actual.Name, d.Expected.Name)
+	}
+}
+
+// ErrVersionsNotSupported is returned if the driver doesn't
+// implement driver.VersionsDriver.
+var ErrVersionsNotSupported = errors.New("driver does not support listing applied versions")
+
+// ExportManifest writes the applied migrations of the database
+// as JSON manifest to w.
+func ExportManifest(url string, w io.Writer) error {
+	m, err := appliedManifest(url)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// CompareManifest reads a JSON manifest from r, as written by
+// ExportManifest, and compares it with the applied migrations of
+// the database. Names and checksums are compared, applied_at is not.
+func CompareManifest(url string, r io.Reader) ([]Difference, error) {
+	var expected Manifest
+	if err := json.NewDecoder(r).Decode(&expected); err != nil {
+		return nil, err
+	}
+	actual, err := appliedManifest(url)
+	if err != nil {
+		return nil, err
+	}
+	return compareManifests(expected, actual), nil
+}
+
+// appliedManifest returns the manifest of all applied migrations.
+func appliedManifest(url string) (Manifest, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	vd, ok := d.(driver.VersionsDriver)
+	if !ok {
+		return nil, ErrVersionsNotSupported
+	}
+	versions, err := vd.Versions()
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(Manifest, 0, len(versions))
+	for _, v := range versions {
+		entry := ManifestEntry{Version: v.Version, Name: v.Name, Checksum: v.Checksum}
+		if !v.AppliedAt.IsZero() {
+			appliedAt := v.AppliedAt
+			entry.AppliedAt = &appliedAt
+		}
+		m = append(m, entry)
+	}
+	return m, nil
+}
+
+// compareManifests returns the differences between two manifests,
+// sorted by version.
+func compareManifests(expected, actual Manifest) []Difference {
+	entries := func(m Manifest) map[uint64]*ManifestEntry {
+		entries := make(map[uint64]*ManifestEntry)
+		for i := range m {
+			entries[m[i].Version] = &m[i]
+		}
+		return entries
+	}
+	expectedEntries, actualEntries := entries(expected), entries(actual)
+
+	versions := make([]uint64, 0)
+	for version := range expectedEntries {
+		versions = append(versions, version)
+	}
+	for version := range actualEntries {
+		if _, ok := expectedEntries[version]; !ok {
+			versions = append(versions, version)
+		}
+	}
+	sort.Sort(uint64Slice(versions))
+
+	diffs := make([]Difference, 0)
+	for _, version := range versions {
+		e, a := expectedEntries[version], actualEntries[version]
+		if e == nil || a == nil || e.Name != a.Name || e.Checksum != a.Checksum {
+			diffs = append(diffs, Difference{Version: version, Expected: e, Actual: a})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_create_foo.up.sql": "CREATE TABLE foo ();",
+		"002_create_bar.up.sql": "CREATE TABLE bar ();",
+		"003_create_baz.up.sql": "CREATE TABLE baz ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, +2); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportManifest("fake://", &buf); err != nil {
+		t.Fatal(err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m[0].Version != 1 || m[0].Name != "create_foo" || m[0].Checksum == "" || m[0].AppliedAt == nil {
+		t.Fatalf("Unexpected manifest %v", m)
+	}
+
+	// round trip
+	diffs, err := CompareManifest("fake://", bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %v", diffs)
+	}
+
+	// manifest expects version 3 and a different checksum for version 2
+	expected := Manifest{m[0], m[1], ManifestEntry{Version: 3, Name: "create_baz"}}
+	expected[1].Checksum = "tampered"
+	b, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = CompareManifest("fake://", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 differences, got %v", diffs)
+	}
+	if diffs[0].Version != 2 || diffs[0].Actual.Checksum != m[1].Checksum {
+		t.Errorf("Expected checksum difference for version 2, got %v", diffs[0])
+	}
+	if diffs[1].Version != 3 || diffs[1].Actual != nil {
+		t.Errorf("Expected version 3 missing in database, got %v", diffs[1])
+	}
+
+	// database has a version missing in the manifest
+	b, err = json.Marshal(Manifest{m[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err = CompareManifest("fake://", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Version != 2 || diffs[0].Expected != nil {
+		t.Errorf("Expected version 2 missing in manifest, got %v", diffs)
+	}
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// Metrics receives structured events for instrumentation, e.g.
+// Prometheus counters and histograms. Calls happen synchronously
+// from the migration loop.
+type Metrics interface {
+
+	// MigrationStarted is called before f is handed to the driver.
+	MigrationStarted(f file.File)
+
+	// MigrationCompleted is called after the driver migrated f
+	// with the time it took and the error returned, if any.
+	MigrationCompleted(f file.File, d time.Duration, err error)
+}
+
+// RetryMetrics is an optional interface a Metrics sink can implement
+// to count retries of drivers implementing driver.RetryDriver.
+type RetryMetrics interface {
+
+	// MigrationRetried is called before a failed attempt of f is retried.
+	MigrationRetried(f file.File, attempt int, err error)
+}
+
+// noopMetrics discards all events.
+type noopMetrics struct{}
+
+func (noopMetrics) MigrationStarted(f file.File)                               {}
+func (noopMetrics) MigrationCompleted(f file.File, d time.Duration, err error) {}
+
+// metrics is an internal variable that holds the metrics sink
+var metrics Metrics = noopMetrics{}
+
+// SetMetrics sets the metrics sink. Pass nil to discard all events.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metrics = m
+}
+
+// configureRetries reports retries of the driver to the logger
+// and the metrics sink.
+func configureRetries(d driver.Driver) {
+	rd, ok := d.(driver.RetryDriver)
+	if !ok {
+		return
+	}
+	rd.OnRetry(func(f file.File, attempt int, err error) {
+		logger.Printf("warning: %s failed on attempt %v, retrying: %v", f.FileName, attempt, err)
+		if rm, ok := metrics.(RetryMetrics); ok {
+			rm.MigrationRetried(f, attempt, err)
+		}
+	})
+}
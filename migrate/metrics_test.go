@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chr4/migrate/file"
+)
+
+// fakeMetrics records all events.
+type fakeMetrics struct {
+	started   []uint64
+	completed []uint64
+	durations []time.Duration
+	errs      []error
+	retried   []uint64
+}
+
+func (m *fakeMetrics) MigrationStarted(f file.File) {
+	m.started = append(m.started, f.Version)
+}
+
+func (m *fakeMetrics) MigrationCompleted(f file.File, d time.Duration, err error) {
+	m.completed = append(m.completed, f.Version)
+	m.durations = append(m.durations, d)
+	m.errs = append(m.errs, err)
+}
+
+func (m *fakeMetrics) MigrationRetried(f file.File, attempt int, err error) {
+	m.retried = append(m.retried, f.Version)
+}
+
+func TestMetrics(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+		"003_migration3.up.sql": "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	m := &fakeMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	fakeSlow.reset()
+	if err := Up("fakeslow://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+
+	expect := []uint64{1, 2, 3}
+	if len(m.started) != len(expect) || len(m.completed) != len(expect) {
+		t.Fatalf("Expected %v started and completed events, got %v and %v", len(expect), len(m.started), len(m.completed))
+	}
+	for i, version := range expect {
+		if m.started[i] != version || m.completed[i] != version {
+			t.Errorf("Expected events for version %v at index %v, got %v and %v", version, i, m.started[i], m.completed[i])
+		}
+		if m.durations[i] < fakeSlow.delay {
+			t.Errorf("Expected duration of at least %v for version %v, got %v", fakeSlow.delay, version, m.durations[i])
+		}
+	}
+	if m.errs[0] != nil || m.errs[1] != nil {
+		t.Errorf("Expected no errors for versions 1 and 2, got %v", m.errs[:2])
+	}
+	if m.errs[2] == nil {
+		t.Error("Expected error for version 3")
+	}
+}
+
+func TestRetryMetrics(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "RETRY",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	m := &fakeMetrics{}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	fakeRetry.reset()
+	if err := Up("fakeretry://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.retried) != 1 || m.retried[0] != 2 {
+		t.Errorf("Expected one retry of version 2, got %v", m.retried)
+	}
+	if !strings.Contains(buf.String(), "002_migration2.up.sql failed on attempt 1, retrying") {
+		t.Errorf("Expected retry to be logged, got %q", buf.String())
+	}
+}
+
+func TestEstimatedDuration(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "-- migrate:estimated 1m\nSELECT 1",
+		"002_migration2.up.sql": "-- migrate:estimated 1ms\nSELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	fakeSlow.reset()
+	if err := Up("fakeslow://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "001_migration1.up.sql took") {
+		t.Errorf("Expected no warning for migration under estimate, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "002_migration2.up.sql took") || !strings.Contains(buf.String(), "estimated 1ms") {
+		t.Errorf("Expected warning for migration over estimate, got %q", buf.String())
+	}
+
+	// a large factor tolerates the overrun
+	SetOverrunFactor(1000)
+	defer SetOverrunFactor(1)
+	buf.Reset()
+	fakeSlow.reset()
+	if err := Up("fakeslow://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "took") {
+		t.Errorf("Expected no warning within overrun factor, got %q", buf.String())
+	}
+}
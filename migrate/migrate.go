@@ -3,13 +3,19 @@
 package migrate
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/signal"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chr4/migrate/driver"
 	"github.com/chr4/migrate/file"
@@ -18,45 +24,103 @@ import (
 
 // Up applies all available migrations
 func Up(url, migrationsPath string) (err error) {
-	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
-	defer d.Close()
+	_, err = UpWithResult(url, migrationsPath)
+	return
+}
+
+// UpDSN is like Up, but connects to the database described by dsn.
+func UpDSN(dsn driver.DSN, migrationsPath string) error {
+	return Up(dsn.String(), migrationsPath)
+}
+
+// UpWithResult applies all available migrations like Up and
+// returns the outcome of each file, e.g. for reporting. If a
+// migration fails, its error is returned along with the result.
+func UpWithResult(url, migrationsPath string) (*Result, error) {
+	d, err := driver.New(url)
 	if err != nil {
-		return
+		return nil, err
+	}
+	return upWithResult(d, url, migrationsPath)
+}
+
+// upWithResult implements UpWithResult for an initialized driver,
+// which is closed afterwards.
+func upWithResult(d driver.Driver, url, migrationsPath string) (*Result, error) {
+	result, err := runUp(d, migrationsPath, filenameRegex(d))
+	if result != nil {
+		saveCheckpoints(d, url, migrationsPath, result)
 	}
+	return result, err
+}
 
-	// Discarding error, files.ToLastFrom() always returns Files, nil
-	applyMigrationFiles, _ := files.ToLastFrom(version)
+// runUp applies the pending files matching filenameRegex like Up,
+// including the baseline, AutoRollback and the post up check, and
+// closes d afterwards. The result is nil if no file was started.
+func runUp(d driver.Driver, migrationsPath string, filenameRegex *regexp.Regexp) (*Result, error) {
+	files, version, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDriver(d)
 
-	if len(applyMigrationFiles) > 0 {
-		for _, f := range applyMigrationFiles {
-			err = d.Migrate(f)
-			if err != nil {
-				return
-			}
-		}
+	version, err = applyBaseline(d, version)
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	applyMigrationFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return nil, err
+	}
+
+	result := runWithResult(d, version, func(result *Result) error {
+		if err := migrateUpFiles(d, files, applyMigrationFiles, autoRollback, result); err != nil {
+			return err
+		}
+		return runPostUpCheck(d)
+	})
+	return result, result.Err
 }
 
-// Down rolls back all migrations
+// UpWithDB applies all available migrations using an existing *sql.DB,
+// e.g. the connection pool of an application. The driver is chosen by the
+// database/sql driver of db and db is left open. If extension is empty,
+// the driver's filename extension is used.
+func UpWithDB(db *sql.DB, migrationsPath, extension string) error {
+	d, err := driver.NewWithDB(db)
+	if err != nil {
+		return err
+	}
+	if extension == "" {
+		extension = d.FilenameExtension()
+	}
+	return upWithDriver(d, migrationsPath, file.FilenameRegex(extension))
+}
+
+// Down rolls back all migrations. It requires AllowDown.
 func Down(url, migrationsPath string) (err error) {
-	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	url, err = checkAllowDown(url)
 	if err != nil {
 		return
 	}
+	return down(url, migrationsPath)
+}
 
-	// Discarding error, files.ToLastFrom() always returns Files, nil
-	applyMigrationFiles, _ := files.ToFirstFrom(version)
+// down rolls back all migrations without checking AllowDown
+func down(url, migrationsPath string) (err error) {
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return
+	}
+	defer closeDriver(d)
 
-	if len(applyMigrationFiles) > 0 {
-		for _, f := range applyMigrationFiles {
-			err = d.Migrate(f)
-			if err != nil {
-				break
-			}
-		}
+	applyMigrationFiles, err := allDownFiles(files, version)
+	if err != nil {
+		return
 	}
+
+	err = migrateFiles(d, applyMigrationFiles)
 	return
 }
 
@@ -71,9 +135,13 @@ func Redo(url, migrationsPath string) (err error) {
 	return
 }
 
-// Reset runs the down and up migration function
+// Reset runs the down and up migration function. It requires AllowDown.
 func Reset(url, migrationsPath string) (err error) {
-	err = Down(url, migrationsPath)
+	url, err = checkAllowDown(url)
+	if err != nil {
+		return
+	}
+	err = down(url, migrationsPath)
 	if err != nil {
 		return
 	}
@@ -84,29 +152,54 @@ func Reset(url, migrationsPath string) (err error) {
 // Migrate applies relative +n/-n migrations
 func Migrate(url, migrationsPath string, relativeN int) (err error) {
 	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
-	defer d.Close()
-
 	if err != nil {
 		return
 	}
+	defer closeDriver(d)
 
 	applyMigrationFiles, err := files.From(version, relativeN)
 	if err != nil {
 		return
 	}
 
-	if len(applyMigrationFiles) > 0 && relativeN != 0 {
-		for _, f := range applyMigrationFiles {
-			err = d.Migrate(f)
-			if err != nil {
-				return
-			}
-		}
-		return
+	if relativeN != 0 {
+		err = migrateFiles(d, applyMigrationFiles)
 	}
 	return
 }
 
+// ErrNoPendingMigrations is returned by Next if all
+// migrations have been applied.
+var ErrNoPendingMigrations = errors.New("no pending migrations")
+
+// Next applies the next pending up migration only and returns it,
+// e.g. for running tests in between migrations. It returns
+// ErrNoPendingMigrations if there is none.
+func Next(url, migrationsPath string) (*file.File, error) {
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDriver(d)
+
+	applyMigrationFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(applyMigrationFiles) == 0 {
+		return nil, ErrNoPendingMigrations
+	}
+
+	f := applyMigrationFiles[0]
+	if err := f.ReadContent(); err != nil {
+		return nil, err
+	}
+	if err := migrateFiles(d, file.Files{f}); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
 // Version returns the current migration version
 func Version(url, migrationsPath string) (version uint64, err error) {
 	d, err := driver.New(url)
@@ -116,13 +209,74 @@ func Version(url, migrationsPath string) (version uint64, err error) {
 	return d.Version()
 }
 
+// PendingCount returns the number of up migrations with a version
+// greater than the current version, e.g. for health checks. Unlike
+// Up, it neither acquires the migration lock nor reads file contents.
+func PendingCount(url, migrationsPath string) (int, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	files, err := readMigrationFiles(migrationsPath, filenameRegex(d))
+	if err != nil {
+		return 0, err
+	}
+	version, err := d.Version()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, mf := range files {
+		if mf.Version > version && mf.UpFile != nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ErrVersionInfoNotSupported is returned if the driver doesn't
+// implement driver.VersionInfoDriver.
+var ErrVersionInfoNotSupported = errors.New("driver does not support version info")
+
+// VersionInfo returns the current migration version and its name
+func VersionInfo(url string) (version uint64, name string, err error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer d.Close()
+
+	vi, ok := d.(driver.VersionInfoDriver)
+	if !ok {
+		return 0, "", ErrVersionInfoNotSupported
+	}
+	return vi.VersionInfo()
+}
+
 // Create creates new migration files on disk
 func Create(url, migrationsPath, name string) (*file.MigrationFile, error) {
+	return CreateWithLayout(url, migrationsPath, name, nil)
+}
+
+// CreateWithLayout creates new migration files in a subdirectory of
+// migrationsPath returned by layoutFn, e.g. one directory per year.
+// Missing directories are created. The next version is determined from
+// all subdirectories, use Recursive to read the files when migrating.
+// If layoutFn is nil, the files are created in migrationsPath itself.
+func CreateWithLayout(url, migrationsPath, name string, layoutFn func(version uint64, t time.Time) string) (*file.MigrationFile, error) {
 	d, err := driver.New(url)
 	if err != nil {
 		return nil, err
 	}
-	files, err := file.ReadMigrationFiles(migrationsPath, file.FilenameRegex(d.FilenameExtension()))
+	var files file.MigrationFiles
+	if layoutFn != nil {
+		files, err = file.ReadMigrationFilesRecursive(migrationsPath, filenameRegex(d))
+	} else {
+		files, err = readMigrationFiles(migrationsPath, filenameRegex(d))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -136,13 +290,23 @@ func Create(url, migrationsPath, name string) (*file.MigrationFile, error) {
 	versionStr := strconv.FormatUint(version, 10)
 
 	length := 4 // TODO(mattes) check existing files and try to guess length
-	if len(versionStr)%length != 0 {
+	if file.UsesSemanticVersions() {
+		// incrementing the encoded version bumps the patch component
+		versionStr = file.FormatSemver(version)
+	} else if len(versionStr)%length != 0 {
 		versionStr = strings.Repeat("0", length-len(versionStr)%length) + versionStr
 	}
 
 	filenamef := "%s_%s.%s.%s"
 	name = strings.Replace(name, " ", "_", -1)
 
+	if layoutFn != nil {
+		migrationsPath = path.Join(migrationsPath, layoutFn(version, time.Now()))
+		if err := os.MkdirAll(migrationsPath, 0755); err != nil {
+			return nil, err
+		}
+	}
+
 	mfile := &file.MigrationFile{
 		Version: version,
 		UpFile: &file.File{
@@ -171,24 +335,351 @@ func Create(url, migrationsPath, name string) (*file.MigrationFile, error) {
 	return mfile, nil
 }
 
+// ShowMigration returns the content that would be executed for the given
+// version and direction. No database connection is required. Like the
+// other functions it honours Recursive and SetNameFilter. Registered Go
+// migrations have no content to show and return an error.
+func ShowMigration(migrationsPath string, version uint64, d direction.Direction) ([]byte, error) {
+	// match any filename extension, since there is no driver
+	files, err := readMigrationFiles(migrationsPath, file.FilenameRegex(`[^.]+`))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mf := range files {
+		if mf.Version != version {
+			continue
+		}
+		f := mf.UpFile
+		if d == direction.Down {
+			f = mf.DownFile
+		}
+		if f == nil {
+			break
+		}
+		if f.GoFunc != nil {
+			return nil, fmt.Errorf("%s is a Go migration", f.FileName)
+		}
+		if err := f.ReadContent(); err != nil {
+			return nil, err
+		}
+		return f.Content, nil
+	}
+	return nil, fmt.Errorf("no migration file found for version %v", version)
+}
+
+// overrunFactor is an internal variable that holds the factor
+// by which a migration may exceed its estimated duration
+var overrunFactor = 1.0
+
+// SetOverrunFactor sets the factor by which a migration may exceed the
+// duration estimated with the "-- migrate:estimated 2m" directive before
+// a warning is logged. It defaults to 1, warning on any overrun.
+func SetOverrunFactor(factor float64) {
+	overrunFactor = factor
+}
+
+// dependencyOrder is an internal variable that holds the state
+// of dependency ordering
+var dependencyOrder = false
+
+// DependencyOrder enables ordering of up migrations by their
+// dependencies declared with the "-- migrate:requires 3,4" directive,
+// instead of purely by version. See file.MigrationFiles.TopoSort.
+// It requires drivers implementing driver.VersionsDriver.
+func DependencyOrder(enable bool) {
+	dependencyOrder = enable
+}
+
+// pendingUpFiles returns all up migration files after version
+// in the order they should be applied. With DependencyOrder, files
+// are pending unless d lists them as applied, since their order
+// doesn't follow their versions.
+func pendingUpFiles(d driver.Driver, files *file.MigrationFiles, version uint64) (file.Files, error) {
+	if !dependencyOrder {
+		// Discarding error, files.ToLastFrom() always returns Files, nil
+		applyMigrationFiles, _ := files.ToLastFrom(version)
+		return applyMigrationFiles, nil
+	}
+
+	sorted, err := files.TopoSort()
+	if err != nil {
+		return nil, err
+	}
+	vd, ok := d.(driver.VersionsDriver)
+	if !ok {
+		return nil, ErrVersionsNotSupported
+	}
+	versions, err := vd.Versions()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]bool, len(versions))
+	for _, v := range versions {
+		applied[v.Version] = true
+	}
+	applyMigrationFiles := make(file.Files, 0)
+	for _, f := range sorted {
+		if !applied[f.Version] {
+			applyMigrationFiles = append(applyMigrationFiles, f)
+		}
+	}
+	return applyMigrationFiles, nil
+}
+
+// migrateFiles applies the given files in order and stops
+// at the first error or once the run timeout has passed.
+func migrateFiles(d driver.Driver, files file.Files) error {
+	return migrateUpFiles(d, nil, files, false, nil)
+}
+
+// migrateFile prepares a single file and hands it to the driver.
+func migrateFile(d driver.Driver, f file.File) error {
+	return migrateFileContext(context.Background(), d, f)
+}
+
+// migrateFileContext is like migrateFile, but drivers implementing
+// driver.ContextMigrator abort the migration once ctx is done.
+func migrateFileContext(ctx context.Context, d driver.Driver, f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if f.MixedLineEndings {
+		logger.Printf("warning: %s has mixed line endings, see file.NormalizeLineEndings", f.FileName)
+	}
+	if f.Direction == direction.Up {
+		f.Checksum = checksum(f.Content)
+		f.RunLabel = runLabel
+	}
+	if skip, err := applyGuard(d, &f); err != nil || skip {
+		return err
+	}
+	if err := configureNotices(d); err != nil {
+		return err
+	}
+	configureRetries(d)
+	configureBatchProgress(d)
+	configureConsistency(d)
+	configureExtensions(d)
+
+	metrics.MigrationStarted(f)
+	endSpan := traceFile(d, f)
+	start := time.Now()
+	var err error
+	if f.VersionOnly {
+		err = removeVersion(d, f)
+	} else if cm, ok := d.(driver.ContextMigrator); ok && f.GoFunc == nil {
+		err = cm.MigrateContext(ctx, f)
+	} else if f.GoFunc != nil {
+		err = migrateGo(d, f)
+	} else {
+		err = d.Migrate(f)
+	}
+	elapsed := time.Since(start)
+	metrics.MigrationCompleted(f, elapsed, err)
+	endSpan(err)
+
+	if f.Estimated > 0 && float64(elapsed) > float64(f.Estimated)*overrunFactor {
+		logger.Printf("warning: %s took %v, estimated %v", f.FileName, elapsed, f.Estimated)
+	}
+	return err
+}
+
+// removeVersion removes the version of f, a file.File.VersionOnly
+// file, without handing f to the driver's Migrate
+func removeVersion(d driver.Driver, f file.File) error {
+	r, ok := d.(driver.VersionRemover)
+	if !ok {
+		return fmt.Errorf("%T can't remove version %v without a down migration", d, f.Version)
+	}
+	return r.RemoveVersion(f.Version)
+}
+
 // initDriverAndReadMigrationFilesAndGetVersion is a small helper
-// function that is common to most of the migration funcs
+// function that is common to most of the migration funcs.
+// The returned driver holds the migration lock (if supported)
+// and must be released with closeDriver.
 func initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath string) (driver.Driver, *file.MigrationFiles, uint64, error) {
 	d, err := driver.New(url)
 	if err != nil {
 		return nil, nil, 0, err
 	}
-	files, err := file.ReadMigrationFiles(migrationsPath, file.FilenameRegex(d.FilenameExtension()))
+	files, version, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex(d))
 	if err != nil {
-		d.Close() // TODO what happens with errors from this func?
 		return nil, nil, 0, err
 	}
+	return d, files, version, nil
+}
+
+// lockAndReadMigrationFilesAndGetVersion acquires the migration lock
+// (if supported) for an initialized driver. The driver is closed on error.
+func lockAndReadMigrationFilesAndGetVersion(d driver.Driver, migrationsPath string, filenameRegex *regexp.Regexp) (*file.MigrationFiles, uint64, error) {
+	if err := checkGoMigrations(d); err != nil {
+		d.Close()
+		return nil, 0, err
+	}
+	if err := lock(d); err != nil {
+		d.Close() // TODO what happens with errors from this func?
+		return nil, 0, err
+	}
+	files, err := readMigrationFiles(migrationsPath, filenameRegex)
+	if err != nil {
+		closeDriver(d) // TODO what happens with errors from this func?
+		return nil, 0, err
+	}
+	if jd, ok := d.(driver.JSONValidatingDriver); ok {
+		if err := files.ValidateJSON(jd.JSONSchema()); err != nil {
+			closeDriver(d)
+			return nil, 0, err
+		}
+	}
 	version, err := d.Version()
 	if err != nil {
-		d.Close() // TODO what happens with errors from this func?
-		return nil, nil, 0, err
+		closeDriver(d) // TODO what happens with errors from this func?
+		return nil, 0, err
+	}
+	startRunSpan(d, version)
+	return &files, version, nil
+}
+
+// progressFunc is an internal variable that holds
+// the progress callback
+var progressFunc func(current, total int, f file.File)
+
+// SetProgressFunc sets a callback which is called before each file
+// is migrated with the 1-based index of the file and the total number
+// of files scheduled for this run. Pass nil to remove the callback.
+func SetProgressFunc(fn func(current, total int, f file.File)) {
+	progressFunc = fn
+}
+
+// recursive is an internal variable that holds the state of
+// recursive migration file reading
+var recursive = false
+
+// Recursive enables reading migration files from all subdirectories
+// of the migrations path. Versions must be unique across all directories.
+func Recursive(enable bool) {
+	recursive = enable
+}
+
+// filenameRegex returns the regular expression matching
+// all migration files accepted by the driver
+func filenameRegex(d driver.Driver) *regexp.Regexp {
+	return file.FilenameRegexExtensions(driver.FilenameExtensions(d))
+}
+
+// nameFilter is an internal variable that holds the
+// migration name filter
+var nameFilter func(name string) bool
+
+// SetNameFilter sets a filter for migration names. Migrations for which
+// fn returns false are excluded from the collection entirely, as if their
+// files didn't exist. Pass nil to remove the filter.
+//
+// Be aware that this changes version ordering semantics: migrations are
+// still applied in order of their versions, so a previously excluded
+// migration with a version lower than the current version will never
+// be applied by Up once it is included again.
+func SetNameFilter(fn func(name string) bool) {
+	nameFilter = fn
+}
+
+// readMigrationFiles reads the migration files matching filenameRegex
+// and adds the registered Go migrations
+func readMigrationFiles(migrationsPath string, filenameRegex *regexp.Regexp) (file.MigrationFiles, error) {
+	files, err := readUnfilteredMigrationFiles(migrationsPath, filenameRegex)
+	if err != nil || nameFilter == nil {
+		return files, err
+	}
+
+	filtered := make(file.MigrationFiles, 0, len(files))
+	for _, mf := range files {
+		if nameFilter(migrationName(mf)) {
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, nil
+}
+
+// readUnfilteredMigrationFiles is like readMigrationFiles,
+// but ignores the name filter
+func readUnfilteredMigrationFiles(migrationsPath string, filenameRegex *regexp.Regexp) (file.MigrationFiles, error) {
+	var files file.MigrationFiles
+	var err error
+	if recursive {
+		files, err = file.ReadMigrationFilesRecursive(migrationsPath, filenameRegex)
+	} else {
+		files, err = file.ReadMigrationFiles(migrationsPath, filenameRegex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return addGoMigrations(files)
+}
+
+// migrationName returns the name of the up or down file of mf
+func migrationName(mf file.MigrationFile) string {
+	if mf.UpFile != nil {
+		return mf.UpFile.Name
+	} else if mf.DownFile != nil {
+		return mf.DownFile.Name
+	}
+	return ""
+}
+
+// closeDriver releases the migration lock (if supported),
+// ends the span of the run and closes the driver.
+func closeDriver(d driver.Driver) error {
+	endRunSpan(d)
+	if l, ok := d.(driver.Locker); ok {
+		if err := l.Unlock(); err != nil {
+			d.Close()
+			return err
+		}
+	}
+	return d.Close()
+}
+
+// requireLock is an internal variable that holds the state of
+// lock enforcement
+var requireLock = false
+
+// ErrLockNotSupported is returned if locking is required, but
+// the driver doesn't implement driver.Locker.
+var ErrLockNotSupported = errors.New("driver does not support locking")
+
+// RequireLock enforces locking. If enabled, running migrations against
+// a driver which doesn't implement driver.Locker returns
+// ErrLockNotSupported instead of silently proceeding without a lock.
+func RequireLock(require bool) {
+	requireLock = require
+}
+
+// lock acquires the migration lock if the driver supports it.
+func lock(d driver.Driver) error {
+	l, ok := d.(driver.Locker)
+	if !ok {
+		if requireLock {
+			return ErrLockNotSupported
+		}
+		logger.Printf("warning: %T does not support locking, running migrations unlocked", d)
+		return nil
+	}
+	return l.Lock()
+}
+
+// logger is an internal variable that holds the logger for
+// warnings. Messages are discarded by default.
+var logger = log.New(ioutil.Discard, "", 0)
+
+// SetLogger sets the logger for warnings. Pass nil to
+// discard all messages.
+func SetLogger(l *log.Logger) {
+	if l == nil {
+		l = log.New(ioutil.Discard, "", 0)
 	}
-	return d, &files, version, nil
+	logger = l
 }
 
 // interrupts is an internal variable that holds the state of
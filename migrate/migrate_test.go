@@ -1,11 +1,25 @@
 package migrate
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"testing"
-	// Ensure imports for each driver we wish to test
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
 
+	// Ensure imports for each driver we wish to test
 	_ "github.com/chr4/migrate/driver/postgres"
 	_ "github.com/chr4/migrate/driver/sqlite3"
 )
@@ -56,7 +70,47 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateWithLayout(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	layout := func(version uint64, now time.Time) string {
+		if version == 1 || now.IsZero() {
+			t.Errorf("Unexpected layout arguments %v %v", version, now)
+		}
+		return "2024"
+	}
+	for _, name := range []string{"migration2", "migration3"} {
+		mf, err := CreateWithLayout("fake://", tmpdir, name, layout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mf.UpFile.Path != path.Join(tmpdir, "2024") {
+			t.Errorf("Expected file in subdirectory 2024, got %v", mf.UpFile.Path)
+		}
+	}
+
+	files, err := file.ReadMigrationFilesRecursive(tmpdir, file.FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 versions, got %v", len(files))
+	}
+	for i, expect := range []string{"0002_migration2.up.sql", "0003_migration3.up.sql"} {
+		f := files[i+1].UpFile
+		if f.FileName != expect || f.Path != path.Join(tmpdir, "2024") {
+			t.Errorf("Expected %v in 2024, got %v in %v", expect, f.FileName, f.Path)
+		}
+	}
+}
+
 func TestReset(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
 	for _, driverUrl := range driverUrls {
 		t.Logf("Test driver: %s", driverUrl)
 		tmpdir, err := ioutil.TempDir("/", "migrate-test")
@@ -67,9 +121,8 @@ func TestReset(t *testing.T) {
 		Create(driverUrl, tmpdir, "migration1")
 		Create(driverUrl, tmpdir, "migration2")
 
-		errs, ok := Reset(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Reset(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err := Version(driverUrl, tmpdir)
 		if err != nil {
@@ -82,6 +135,9 @@ func TestReset(t *testing.T) {
 }
 
 func TestDown(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
 	for _, driverUrl := range driverUrls {
 		t.Logf("Test driver: %s", driverUrl)
 		tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
@@ -92,9 +148,8 @@ func TestDown(t *testing.T) {
 		Create(driverUrl, tmpdir, "migration1")
 		Create(driverUrl, tmpdir, "migration2")
 
-		errs, ok := Reset(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Reset(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err := Version(driverUrl, tmpdir)
 		if err != nil {
@@ -104,9 +159,8 @@ func TestDown(t *testing.T) {
 			t.Fatalf("Expected version 2, got %v", version)
 		}
 
-		errs, ok = Down(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Down(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err = Version(driverUrl, tmpdir)
 		if err != nil {
@@ -119,6 +173,9 @@ func TestDown(t *testing.T) {
 }
 
 func TestUp(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
 	for _, driverUrl := range driverUrls {
 		t.Logf("Test driver: %s", driverUrl)
 		tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
@@ -129,9 +186,8 @@ func TestUp(t *testing.T) {
 		Create(driverUrl, tmpdir, "migration1")
 		Create(driverUrl, tmpdir, "migration2")
 
-		errs, ok := Down(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Down(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err := Version(driverUrl, tmpdir)
 		if err != nil {
@@ -141,9 +197,8 @@ func TestUp(t *testing.T) {
 			t.Fatalf("Expected version 0, got %v", version)
 		}
 
-		errs, ok = Up(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Up(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err = Version(driverUrl, tmpdir)
 		if err != nil {
@@ -156,6 +211,9 @@ func TestUp(t *testing.T) {
 }
 
 func TestRedo(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
 	for _, driverUrl := range driverUrls {
 		t.Logf("Test driver: %s", driverUrl)
 		tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
@@ -166,9 +224,8 @@ func TestRedo(t *testing.T) {
 		Create(driverUrl, tmpdir, "migration1")
 		Create(driverUrl, tmpdir, "migration2")
 
-		errs, ok := Reset(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Reset(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err := Version(driverUrl, tmpdir)
 		if err != nil {
@@ -178,9 +235,8 @@ func TestRedo(t *testing.T) {
 			t.Fatalf("Expected version 2, got %v", version)
 		}
 
-		errs, ok = Redo(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Redo(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err = Version(driverUrl, tmpdir)
 		if err != nil {
@@ -193,6 +249,9 @@ func TestRedo(t *testing.T) {
 }
 
 func TestMigrate(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
 	for _, driverUrl := range driverUrls {
 		t.Logf("Test driver: %s", driverUrl)
 		tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
@@ -203,9 +262,8 @@ func TestMigrate(t *testing.T) {
 		Create(driverUrl, tmpdir, "migration1")
 		Create(driverUrl, tmpdir, "migration2")
 
-		errs, ok := Reset(driverUrl, tmpdir)
-		if !ok {
-			t.Fatal(errs)
+		if err := Reset(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
 		}
 		version, err := Version(driverUrl, tmpdir)
 		if err != nil {
@@ -215,9 +273,8 @@ func TestMigrate(t *testing.T) {
 			t.Fatalf("Expected version 2, got %v", version)
 		}
 
-		errs, ok = Migrate(driverUrl, tmpdir, -2)
-		if !ok {
-			t.Fatal(errs)
+		if err := Migrate(driverUrl, tmpdir, -2); err != nil {
+			t.Fatal(err)
 		}
 		version, err = Version(driverUrl, tmpdir)
 		if err != nil {
@@ -227,9 +284,8 @@ func TestMigrate(t *testing.T) {
 			t.Fatalf("Expected version 0, got %v", version)
 		}
 
-		errs, ok = Migrate(driverUrl, tmpdir, +1)
-		if !ok {
-			t.Fatal(errs)
+		if err := Migrate(driverUrl, tmpdir, +1); err != nil {
+			t.Fatal(err)
 		}
 		version, err = Version(driverUrl, tmpdir)
 		if err != nil {
@@ -240,3 +296,1120 @@ func TestMigrate(t *testing.T) {
 		}
 	}
 }
+
+func TestNext(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+		"003_migration3.up.sql":   "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	for _, expect := range []uint64{1, 2, 3} {
+		f, err := Next("fake://", tmpdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Version != expect || f.Direction != direction.Up || len(f.Content) == 0 {
+			t.Errorf("Expected up file of version %v, got %v", expect, f)
+		}
+		if version, _ := fake.Version(); version != expect {
+			t.Errorf("Expected version %v, got %v", expect, version)
+		}
+	}
+
+	if f, err := Next("fake://", tmpdir); err != ErrNoPendingMigrations || f != nil {
+		t.Errorf("Expected ErrNoPendingMigrations, got %v %v", f, err)
+	}
+	if len(fake.applied) != 3 {
+		t.Errorf("Expected 3 applied files, got %v", len(fake.applied))
+	}
+}
+
+func TestPendingCount(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+		"003_migration3.up.sql":   "SELECT 3",
+		"004_migration4.down.sql": "SELECT 4",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if count, err := PendingCount("fake://", tmpdir); err != nil || count != 3 {
+		t.Errorf("Expected 3 pending migrations, got %v (%v)", count, err)
+	}
+	if _, err := Next("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := PendingCount("fake://", tmpdir); err != nil || count != 2 {
+		t.Errorf("Expected 2 pending migrations, got %v (%v)", count, err)
+	}
+
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := PendingCount("fake://", tmpdir); err != nil || count != 0 {
+		t.Errorf("Expected no pending migrations, got %v (%v)", count, err)
+	}
+	if len(fake.applied) != 3 {
+		t.Errorf("Expected PendingCount not to migrate, got %v applied files", len(fake.applied))
+	}
+}
+
+func TestVersionDirective(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "-- migrate:version 1\nSELECT 1",
+		"003_migration3.up.sql": "-- migrate:version 2\nSELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err == nil || !strings.Contains(err.Error(), "003_migration3.up.sql: version directive 2") {
+		t.Errorf("Expected error for mismatching version directive, got %v", err)
+	}
+	if len(fake.applied) != 1 || fake.applied[0].Version != 1 {
+		t.Errorf("Expected only version 1 to be applied, got %v", fake.applied)
+	}
+}
+
+func TestLock(t *testing.T) {
+	defer RequireLock(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	for _, require := range []bool{false, true} {
+		RequireLock(require)
+
+		fakeLock.reset()
+		if err := Up("fakelock://", tmpdir); err != nil {
+			t.Fatal(err)
+		}
+		if fakeLock.locks != 1 {
+			t.Errorf("Expected lock to be acquired once, got %v", fakeLock.locks)
+		}
+		if fakeLock.locked {
+			t.Error("Expected lock to be released")
+		}
+		if version, _ := fakeLock.Version(); version != 2 {
+			t.Errorf("Expected version 2, got %v", version)
+		}
+
+		fake.reset()
+		err := Up("fake://", tmpdir)
+		version, _ := fake.Version()
+		if require {
+			if err != ErrLockNotSupported {
+				t.Errorf("Expected ErrLockNotSupported, got %v", err)
+			}
+			if version != 0 {
+				t.Errorf("Expected version 0, got %v", version)
+			}
+		} else {
+			if err != nil {
+				t.Error(err)
+			}
+			if version != 2 {
+				t.Errorf("Expected version 2, got %v", version)
+			}
+		}
+	}
+}
+
+func TestShowMigration(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE foo ();",
+		"001_migration1.down.sql": "DROP TABLE foo;",
+		"002_migration2.up.sql":   "CREATE TABLE bar ();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var tests = []struct {
+		version       uint64
+		d             direction.Direction
+		expectContent string
+		expectErr     bool
+	}{
+		{1, direction.Up, "CREATE TABLE foo ();", false},
+		{1, direction.Down, "DROP TABLE foo;", false},
+		{2, direction.Up, "CREATE TABLE bar ();", false},
+		{2, direction.Down, "", true},
+		{3, direction.Up, "", true},
+	}
+
+	for _, test := range tests {
+		content, err := ShowMigration(tmpdir, test.version, test.d)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for version %v", test.version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != test.expectContent {
+			t.Errorf("Expected %q, got %q", test.expectContent, content)
+		}
+	}
+
+	// filtered migrations don't exist
+	SetNameFilter(func(name string) bool { return name != "migration2" })
+	defer SetNameFilter(nil)
+	if _, err := ShowMigration(tmpdir, 2, direction.Up); err == nil {
+		t.Error("Expected error for filtered version 2")
+	}
+
+	defer resetGoMigrations()
+	RegisterGoMigration(3, "reencrypt", updateSecrets(strings.ToUpper, nil), nil)
+	if _, err := ShowMigration(tmpdir, 3, direction.Up); err == nil || !strings.Contains(err.Error(), "3_reencrypt.up.go is a Go migration") {
+		t.Errorf("Expected error for Go migration, got %v", err)
+	}
+}
+
+func TestVersionInfo(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_create_foo.up.sql":   "SELECT 1",
+		"001_create_foo.down.sql": "SELECT 1",
+		"002_create_bar.up.sql":   "SELECT 1",
+		"002_create_bar.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	version, name, err := VersionInfo("fake://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 || name != "create_bar" {
+		t.Errorf("Expected version 2 create_bar, got %v %v", version, name)
+	}
+
+	if err := Migrate("fake://", tmpdir, -1); err != nil {
+		t.Fatal(err)
+	}
+	version, name, err = VersionInfo("fake://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 || name != "create_foo" {
+		t.Errorf("Expected version 1 create_foo, got %v %v", version, name)
+	}
+}
+
+func TestUpWithDB(t *testing.T) {
+	db, err := sql.Open("postgres", driverUrls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// prepare clean database
+	if _, err := db.Exec(`DROP TABLE IF EXISTS schema_migrations;`); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	if err := UpWithDB(db, tmpdir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal("Expected shared DB to remain open:", err)
+	}
+
+	var version uint64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("Expected version 2, got %v", version)
+	}
+}
+
+func TestFilenameExtensions(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":  "SELECT 1",
+		"002_migration2.up.psql": "SELECT 1",
+		"003_migration3.up.txt":  "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeExt.reset()
+	if err := Up("fakeext://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fakeExt.applied) != 2 {
+		t.Fatalf("Expected 2 applied files, got %v", len(fakeExt.applied))
+	}
+	if fakeExt.applied[1].FileName != "002_migration2.up.psql" {
+		t.Errorf("Expected psql file, got %v", fakeExt.applied[1].FileName)
+	}
+
+	// drivers with a single extension ignore other extensions
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 1 {
+		t.Fatalf("Expected 1 applied file, got %v", len(fake.applied))
+	}
+}
+
+func TestProgressFunc(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	type call struct {
+		current, total int
+		version        uint64
+	}
+	var calls []call
+	SetProgressFunc(func(current, total int, f file.File) {
+		calls = append(calls, call{current, total, f.Version})
+	})
+	defer SetProgressFunc(nil)
+
+	fake.reset()
+	if err := Migrate("fake://", tmpdir, +1); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != (call{1, 1, 1}) {
+		t.Errorf("Unexpected progress calls for Migrate: %v", calls)
+	}
+
+	calls = nil
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	expect := []call{{1, 2, 2}, {2, 2, 3}}
+	if len(calls) != len(expect) {
+		t.Fatalf("Expected %v progress calls for Up, got %v", len(expect), calls)
+	}
+	for i := range expect {
+		if calls[i] != expect[i] {
+			t.Errorf("Expected progress call %v, got %v", expect[i], calls[i])
+		}
+	}
+
+	calls = nil
+	if err := Down("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	expect = []call{{1, 3, 3}, {2, 3, 2}, {3, 3, 1}}
+	if len(calls) != len(expect) {
+		t.Fatalf("Expected %v progress calls for Down, got %v", len(expect), calls)
+	}
+	for i := range expect {
+		if calls[i] != expect[i] {
+			t.Errorf("Expected progress call %v, got %v", expect[i], calls[i])
+		}
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_create_foo.up.sql":     "SELECT 1",
+		"002_exp_create_bar.up.sql": "SELECT 1",
+		"003_create_baz.up.sql":     "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	SetNameFilter(func(name string) bool {
+		return !strings.HasPrefix(name, "exp_")
+	})
+	defer SetNameFilter(nil)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 2 {
+		t.Fatalf("Expected 2 applied files, got %v", len(fake.applied))
+	}
+	for _, f := range fake.applied {
+		if strings.HasPrefix(f.Name, "exp_") {
+			t.Errorf("Expected %v to be excluded", f.FileName)
+		}
+	}
+	if version, _ := fake.Version(); version != 3 {
+		t.Errorf("Expected version 3, got %v", version)
+	}
+
+	// only include experimental migrations
+	SetNameFilter(func(name string) bool {
+		return strings.HasPrefix(name, "exp_")
+	})
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 1 || fake.applied[0].Version != 2 {
+		t.Errorf("Expected only version 2 to be applied, got %v", fake.applied)
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "-- migrate:requires 2,3\nSELECT 1",
+		"002_migration2.up.sql": "-- migrate:requires 4\nSELECT 1",
+		"003_migration3.up.sql": "-- migrate:requires 4\nSELECT 1",
+		"004_migration4.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	DependencyOrder(true)
+	defer DependencyOrder(false)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	expect := []uint64{4, 2, 3, 1}
+	if len(fake.applied) != len(expect) {
+		t.Fatalf("Expected %v applied files, got %v", len(expect), len(fake.applied))
+	}
+	for i, version := range expect {
+		if fake.applied[i].Version != version {
+			t.Fatalf("Expected order %v, got version %v at index %v", expect, fake.applied[i].Version, i)
+		}
+	}
+
+	// lower versions ordered after an applied higher one are pending
+	fake.reset()
+	fake.versions[4] = true
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	expect = []uint64{2, 3, 1}
+	if len(fake.applied) != len(expect) {
+		t.Fatalf("Expected %v applied files, got %v", len(expect), len(fake.applied))
+	}
+	for i, version := range expect {
+		if fake.applied[i].Version != version {
+			t.Fatalf("Expected order %v, got version %v at index %v", expect, fake.applied[i].Version, i)
+		}
+	}
+
+	// cyclic dependencies don't apply anything
+	if err := ioutil.WriteFile(path.Join(tmpdir, "004_migration4.up.sql"), []byte("-- migrate:requires 1\nSELECT 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.reset()
+	if err := Up("fake://", tmpdir); err == nil {
+		t.Error("Expected error for cyclic dependencies")
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected no applied files, got %v", len(fake.applied))
+	}
+}
+
+func TestTreatBareAsUp(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.sql":      "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	file.TreatBareAsUp(true)
+	defer file.TreatBareAsUp(false)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Fatalf("Expected version 2, got %v", version)
+	}
+
+	// version 2 can't be rolled back
+	if err := Reset("fake://", tmpdir); err == nil || !strings.Contains(err.Error(), "no down migration for version 2") {
+		t.Fatalf("Expected error for missing down migration, got %v", err)
+	}
+	if len(fake.applied) != 2 {
+		t.Errorf("Expected nothing to be rolled back, got %v", fake.applied)
+	}
+
+	SkipMissingDown(true)
+	defer SkipMissingDown(false)
+	if err := Reset("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "version 2 has no down migration") {
+		t.Errorf("Expected warning about missing down migration, got %q", buf.String())
+	}
+	if len(fake.applied) != 5 || len(fake.removed) != 1 || fake.removed[0] != 2 || fake.applied[2].FileName != "001_migration1.down.sql" {
+		t.Errorf("Expected versions 2 and 1 to be rolled back and reapplied, got %v", fake.applied)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+}
+
+func TestMixedLineEndingsWarning(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1;\r\nSELECT 2;\n",
+		"002_migration2.up.sql": "SELECT 1;\r\nSELECT 2;\r\n",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	file.NormalizeLineEndings(true)
+	defer file.NormalizeLineEndings(false)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "warning: 001_migration1.up.sql has mixed line endings") {
+		t.Errorf("Expected warning about mixed line endings, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "002_migration2.up.sql") {
+		t.Errorf("Expected no warning for consistent CRLF line endings, got %q", buf.String())
+	}
+	for _, f := range fake.applied {
+		if bytes.Contains(f.Content, []byte("\r")) {
+			t.Errorf("Expected %s to be normalized, got %q", f.FileName, f.Content)
+		}
+	}
+}
+
+// writeMigrationFiles creates a temporary directory holding
+// the given files. Keys are filenames, values the content.
+func writeMigrationFiles(t *testing.T, files map[string]string) string {
+	tmpdir, err := ioutil.TempDir("/tmp", "migrate-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(path.Join(tmpdir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpdir
+}
+
+// fakeDriver is an in-memory driver to test the migrate package
+// without a database. Migration files containing FAIL return an error.
+type fakeDriver struct {
+	versions  map[uint64]bool
+	names     map[uint64]string
+	checksums map[uint64]string
+	appliedAt map[uint64]time.Time
+
+	// applied holds all successfully migrated files in order
+	applied file.Files
+
+	// removed holds the versions removed by RemoveVersion in order
+	removed []uint64
+
+	// closed counts the calls of Close
+	closed int
+
+	// checksumScans counts the calls of Checksums
+	checksumScans int
+}
+
+func (driver *fakeDriver) Initialize(url string) error {
+	if driver.versions == nil {
+		driver.reset()
+	}
+	return nil
+}
+
+func (driver *fakeDriver) Close() error {
+	driver.closed++
+	return nil
+}
+
+func (driver *fakeDriver) FilenameExtension() string {
+	return "sql"
+}
+
+func (driver *fakeDriver) Migrate(f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if bytes.Contains(f.Content, []byte("FAIL")) {
+		return errors.New("fake migration failed")
+	}
+	if f.Direction == direction.Up {
+		driver.versions[f.Version] = true
+		driver.names[f.Version] = f.Name
+		driver.appliedAt[f.Version] = time.Now()
+		if f.Checksum != "" {
+			driver.checksums[f.Version] = f.Checksum
+		}
+	} else if f.Direction == direction.Down {
+		delete(driver.versions, f.Version)
+		delete(driver.names, f.Version)
+		delete(driver.appliedAt, f.Version)
+		delete(driver.checksums, f.Version)
+	}
+	driver.applied = append(driver.applied, f)
+	return nil
+}
+
+func (driver *fakeDriver) RemoveVersion(version uint64) error {
+	delete(driver.versions, version)
+	delete(driver.names, version)
+	delete(driver.appliedAt, version)
+	delete(driver.checksums, version)
+	driver.removed = append(driver.removed, version)
+	return nil
+}
+
+func (driver *fakeDriver) Version() (uint64, error) {
+	var version uint64
+	for v := range driver.versions {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+func (driver *fakeDriver) VersionInfo() (uint64, string, error) {
+	version, err := driver.Version()
+	return version, driver.names[version], err
+}
+
+// appliedMigration allows to refer to driver.AppliedMigration
+// within methods, where the receiver shadows the driver package
+type appliedMigration = driver.AppliedMigration
+
+func (driver *fakeDriver) Versions() ([]appliedMigration, error) {
+	versions := make([]appliedMigration, 0)
+	for v := range driver.versions {
+		versions = append(versions, appliedMigration{
+			Version:   v,
+			Name:      driver.names[v],
+			Checksum:  driver.checksums[v],
+			AppliedAt: driver.appliedAt[v],
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+func (driver *fakeDriver) Checksums() (map[uint64]string, error) {
+	driver.checksumScans++
+	checksums := make(map[uint64]string)
+	for version, checksum := range driver.checksums {
+		checksums[version] = checksum
+	}
+	return checksums, nil
+}
+
+func (driver *fakeDriver) LatestChecksum() (uint64, string, error) {
+	version, err := driver.Version()
+	return version, driver.checksums[version], err
+}
+
+// reset clears all state
+func (driver *fakeDriver) reset() {
+	driver.versions = make(map[uint64]bool)
+	driver.names = make(map[uint64]string)
+	driver.appliedAt = make(map[uint64]time.Time)
+	driver.checksums = make(map[uint64]string)
+	driver.applied = nil
+	driver.removed = nil
+	driver.closed = 0
+	driver.checksumScans = 0
+}
+
+// fakeLockDriver is a fakeDriver implementing driver.Locker
+// and driver.ForceDownDriver.
+type fakeLockDriver struct {
+	fakeDriver
+	locked bool
+	locks  int
+}
+
+func (driver *fakeLockDriver) Lock() error {
+	if driver.locked {
+		return errors.New("already locked")
+	}
+	driver.locked = true
+	driver.locks += 1
+	return nil
+}
+
+func (driver *fakeLockDriver) Unlock() error {
+	driver.locked = false
+	return nil
+}
+
+func (driver *fakeLockDriver) reset() {
+	driver.fakeDriver.reset()
+	driver.locked = false
+	driver.locks = 0
+}
+
+func (driver *fakeLockDriver) ForceDown(version uint64) error {
+	for v := range driver.versions {
+		if v > version {
+			delete(driver.versions, v)
+			delete(driver.names, v)
+			delete(driver.appliedAt, v)
+			delete(driver.checksums, v)
+		}
+	}
+	return nil
+}
+
+// fakeExtDriver is a fakeDriver accepting sql and psql files.
+type fakeExtDriver struct {
+	fakeDriver
+}
+
+func (driver *fakeExtDriver) FilenameExtensions() []string {
+	return []string{"sql", "psql"}
+}
+
+// fakeJSONDriver is a fakeDriver with JSON migration files.
+type fakeJSONDriver struct {
+	fakeDriver
+}
+
+func (driver *fakeJSONDriver) FilenameExtension() string {
+	return "json"
+}
+
+func (driver *fakeJSONDriver) JSONSchema() []byte {
+	return []byte(`{"type": "object", "required": ["collection"], "properties": {"collection": {"type": "string"}}}`)
+}
+
+// fakeTxDriver is a fakeDriver reporting whether its DDL is transactional.
+type fakeTxDriver struct {
+	fakeDriver
+	transactional bool
+}
+
+func (driver *fakeTxDriver) TransactionalDDL() bool {
+	return driver.transactional
+}
+
+// fakeSyntaxDriver is a fakeDriver reporting files containing FAIL
+// as syntax errors.
+type fakeSyntaxDriver struct {
+	fakeDriver
+}
+
+func (driver *fakeSyntaxDriver) CheckSyntax(content []byte) error {
+	if bytes.Contains(content, []byte("FAIL")) {
+		return errors.New("fake syntax error")
+	}
+	return nil
+}
+
+// fakeDirtyDriver is a fakeDriver marking the version dirty
+// if a migration fails.
+type fakeDirtyDriver struct {
+	fakeDriver
+	dirty bool
+}
+
+func (driver *fakeDirtyDriver) Migrate(f file.File) error {
+	if err := driver.fakeDriver.Migrate(f); err != nil {
+		driver.versions[f.Version] = true
+		driver.dirty = true
+		return err
+	}
+	return nil
+}
+
+func (driver *fakeDirtyDriver) Dirty() (bool, uint64, error) {
+	version, err := driver.Version()
+	return driver.dirty, version, err
+}
+
+func (driver *fakeDirtyDriver) ClearDirty() error {
+	driver.dirty = false
+	return nil
+}
+
+func (driver *fakeDirtyDriver) reset() {
+	driver.fakeDriver.reset()
+	driver.dirty = false
+}
+
+// fakeNoticeDriver is a fakeDriver failing migrations containing
+// NOTICE if FailOnNotice is enabled.
+type fakeNoticeDriver struct {
+	fakeDriver
+	failOnNotice bool
+}
+
+func (driver *fakeNoticeDriver) FailOnNotice(enable bool) {
+	driver.failOnNotice = enable
+}
+
+func (driver *fakeNoticeDriver) Migrate(f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if driver.failOnNotice && bytes.Contains(f.Content, []byte("NOTICE")) {
+		return errors.New("fake notice")
+	}
+	return driver.fakeDriver.Migrate(f)
+}
+
+// fakeSlowDriver is a fakeDriver taking at least delay per migration.
+type fakeSlowDriver struct {
+	fakeDriver
+	delay time.Duration
+}
+
+func (driver *fakeSlowDriver) Migrate(f file.File) error {
+	time.Sleep(driver.delay)
+	return driver.fakeDriver.Migrate(f)
+}
+
+// MigrateContext aborts files containing SLOW, which take 100 times the
+// delay. Files containing DEADLINE complete once ctx is done, like files
+// of drivers which can't abort them.
+func (driver *fakeSlowDriver) MigrateContext(ctx context.Context, f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if bytes.Contains(f.Content, []byte("DEADLINE")) {
+		<-ctx.Done()
+		return driver.fakeDriver.Migrate(f)
+	}
+	delay := driver.delay
+	if bytes.Contains(f.Content, []byte("SLOW")) {
+		delay *= 100
+	}
+	select {
+	case <-time.After(delay):
+		return driver.fakeDriver.Migrate(f)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fakeRetryDriver is a fakeDriver retrying migrations containing
+// RETRY once before they succeed.
+type fakeRetryDriver struct {
+	fakeDriver
+	retryFunc func(f file.File, attempt int, err error)
+}
+
+func (driver *fakeRetryDriver) OnRetry(fn func(f file.File, attempt int, err error)) {
+	driver.retryFunc = fn
+}
+
+func (driver *fakeRetryDriver) Migrate(f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if bytes.Contains(f.Content, []byte("RETRY")) && driver.retryFunc != nil {
+		driver.retryFunc(f, 1, errors.New("fake deadlock"))
+	}
+	return driver.fakeDriver.Migrate(f)
+}
+
+// fakeTwoPhaseDriver is a fakeDriver supporting two-phase commits.
+// If failPrepare is set, preparing files containing FAIL fails.
+type fakeTwoPhaseDriver struct {
+	fakeDriver
+	failPrepare bool
+	prepared    map[string]file.File
+	rollbacks   int
+}
+
+// fakePreparedIDs holds the ids of all prepared transactions, which must
+// be unique across the fake databases sharing a server, like in postgres
+var fakePreparedIDs = make(map[string]bool)
+
+func (driver *fakeTwoPhaseDriver) PrepareMigration(f file.File, id string) error {
+	if driver.failPrepare && bytes.Contains(f.Content, []byte("FAIL")) {
+		return errors.New("fake prepare failed")
+	}
+	if fakePreparedIDs[id] {
+		return errors.New("transaction identifier " + id + " is already in use")
+	}
+	fakePreparedIDs[id] = true
+	if driver.prepared == nil {
+		driver.prepared = make(map[string]file.File)
+	}
+	driver.prepared[id] = f
+	return nil
+}
+
+func (driver *fakeTwoPhaseDriver) CommitPrepared(id string) error {
+	f, ok := driver.prepared[id]
+	if !ok {
+		return errors.New("no prepared transaction " + id)
+	}
+	delete(driver.prepared, id)
+	delete(fakePreparedIDs, id)
+	return driver.fakeDriver.Migrate(f)
+}
+
+func (driver *fakeTwoPhaseDriver) RollbackPrepared(id string) error {
+	if _, ok := driver.prepared[id]; !ok {
+		return errors.New("no prepared transaction " + id)
+	}
+	delete(driver.prepared, id)
+	delete(fakePreparedIDs, id)
+	driver.rollbacks += 1
+	return nil
+}
+
+func (driver *fakeTwoPhaseDriver) reset() {
+	driver.fakeDriver.reset()
+	for id := range driver.prepared {
+		delete(fakePreparedIDs, id)
+	}
+	driver.prepared = nil
+	driver.rollbacks = 0
+}
+
+// fakeQueryDriver is a fakeDriver returning rows for all queries.
+type fakeQueryDriver struct {
+	fakeDriver
+	rows    [][]string
+	queries []string
+}
+
+func (driver *fakeQueryDriver) QueryRows(query string, limit int) ([][]string, error) {
+	driver.queries = append(driver.queries, query)
+	if len(driver.rows) > limit {
+		return driver.rows[:limit], nil
+	}
+	return driver.rows, nil
+}
+
+func (driver *fakeQueryDriver) reset() {
+	driver.fakeDriver.reset()
+	driver.rows = nil
+	driver.queries = nil
+}
+
+// fakeBatchDriver is a fakeDriver running files with a batch directive
+// in batches affecting the given numbers of rows.
+type fakeBatchDriver struct {
+	fakeDriver
+	batches      []int64
+	progressFunc func(f file.File, processed int64)
+}
+
+func (driver *fakeBatchDriver) OnBatchProgress(fn func(f file.File, processed int64)) {
+	driver.progressFunc = fn
+}
+
+func (driver *fakeBatchDriver) Migrate(f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if _, ok := f.Directive("batch"); ok {
+		var processed int64
+		for _, n := range driver.batches {
+			processed += n
+			if driver.progressFunc != nil {
+				driver.progressFunc(f, processed)
+			}
+		}
+	}
+	return driver.fakeDriver.Migrate(f)
+}
+
+func (driver *fakeBatchDriver) reset() {
+	driver.fakeDriver.reset()
+	driver.batches = nil
+}
+
+// fakeCloneDriver is a fakeDriver supporting clones. Clones are
+// kept in fakeClones and picked up by new instances by their url.
+type fakeCloneDriver struct {
+	fakeDriver
+	cloned  []string
+	dropped []string
+}
+
+var fakeClones = make(map[string]*fakeDriver)
+
+func (driver *fakeCloneDriver) Initialize(url string) error {
+	if clone, ok := fakeClones[url]; ok {
+		driver.fakeDriver = *clone
+		return nil
+	}
+	return driver.fakeDriver.Initialize(url)
+}
+
+func (driver *fakeCloneDriver) CreateClone() (string, error) {
+	url := fmt.Sprintf("fakeclone://clone%v", len(driver.cloned))
+	clone := &fakeDriver{}
+	clone.reset()
+	for version := range driver.versions {
+		clone.versions[version] = true
+	}
+	fakeClones[url] = clone
+	driver.cloned = append(driver.cloned, url)
+	return url, nil
+}
+
+func (driver *fakeCloneDriver) DropClone(url string) error {
+	delete(fakeClones, url)
+	driver.dropped = append(driver.dropped, url)
+	return nil
+}
+
+func (driver *fakeCloneDriver) reset() {
+	driver.fakeDriver.reset()
+	driver.cloned = nil
+	driver.dropped = nil
+}
+
+// fakeStatementDriver is a fakeDriver running statements one by one,
+// each taking delay. Files containing RETRY fail after their first
+// statement once and are retried. Files with an explain directive
+// report a plan for each SELECT.
+type fakeStatementDriver struct {
+	fakeDriver
+	delay         time.Duration
+	statementFunc func(f file.File, index int, d time.Duration)
+	explainFunc   func(f file.File, index int, plan, reason string)
+}
+
+func (driver *fakeStatementDriver) OnStatement(fn func(f file.File, index int, d time.Duration)) {
+	driver.statementFunc = fn
+}
+
+func (driver *fakeStatementDriver) OnExplain(fn func(f file.File, index int, plan, reason string)) {
+	driver.explainFunc = fn
+}
+
+func (driver *fakeStatementDriver) Migrate(f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	attempts := 1
+	if bytes.Contains(f.Content, []byte("RETRY")) {
+		attempts = 2
+	}
+	_, explain := f.Directive("explain")
+	for attempt := 1; attempt <= attempts; attempt++ {
+		for i, stmt := range file.SplitStatements(f.Content) {
+			if attempt < attempts && i > 0 {
+				break
+			}
+			start := time.Now()
+			time.Sleep(driver.delay)
+			if driver.statementFunc != nil {
+				driver.statementFunc(f, i, time.Since(start))
+			}
+			if explain && driver.explainFunc != nil {
+				if bytes.Contains(stmt, []byte("SELECT")) {
+					driver.explainFunc(f, i, "Result (actual rows=1)", "")
+				} else {
+					driver.explainFunc(f, i, "", "not a SELECT")
+				}
+			}
+		}
+	}
+	return driver.fakeDriver.Migrate(f)
+}
+
+var fake = &fakeDriver{}
+var fakeLock = &fakeLockDriver{}
+var fakeExt = &fakeExtDriver{}
+var fakeJSON = &fakeJSONDriver{}
+var fakeTx = &fakeTxDriver{transactional: true}
+var fakeNonTx = &fakeTxDriver{transactional: false}
+var fakeSyntax = &fakeSyntaxDriver{}
+var fakeDirty = &fakeDirtyDriver{}
+var fakeNotice = &fakeNoticeDriver{}
+var fakeSlow = &fakeSlowDriver{delay: 10 * time.Millisecond}
+var fakeRetry = &fakeRetryDriver{}
+var fakeQuery = &fakeQueryDriver{}
+var fakeTwoPhaseA = &fakeTwoPhaseDriver{}
+var fakeTwoPhaseB = &fakeTwoPhaseDriver{failPrepare: true}
+var fakeBatch = &fakeBatchDriver{}
+var fakeClone = &fakeCloneDriver{}
+var fakeStatement = &fakeStatementDriver{delay: time.Millisecond}
+
+func init() {
+	driver.RegisterDriver("fake", fake)
+	driver.RegisterDriver("fakelock", fakeLock)
+	driver.RegisterDriver("fakeext", fakeExt)
+	driver.RegisterDriver("fakejson", fakeJSON)
+	driver.RegisterDriver("faketx", fakeTx)
+	driver.RegisterDriver("fakenontx", fakeNonTx)
+	driver.RegisterDriver("fakesyntax", fakeSyntax)
+	driver.RegisterDriver("fakedirty", fakeDirty)
+	driver.RegisterDriver("fakenotice", fakeNotice)
+	driver.RegisterDriver("fakeslow", fakeSlow)
+	driver.RegisterDriver("fakeretry", fakeRetry)
+	driver.RegisterDriver("fakequery", fakeQuery)
+	driver.RegisterDriver("fake2pca", fakeTwoPhaseA)
+	driver.RegisterDriver("fake2pcb", fakeTwoPhaseB)
+	driver.RegisterDriver("fakebatch", fakeBatch)
+	driver.RegisterDriver("fakeclone", fakeClone)
+	driver.RegisterDriver("fakestatement", fakeStatement)
+}
+
+func TestCaseInsensitiveMatching(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.UP.SQL": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	file.CaseInsensitiveMatching(true)
+	defer file.CaseInsensitiveMatching(false)
+
+	mf, err := Create("fake://", tmpdir, "migration2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mf.UpFile.FileName != "0002_migration2.up.sql" || mf.DownFile.FileName != "0002_migration2.down.sql" {
+		t.Errorf("Expected lowercase filenames, got %v and %v", mf.UpFile.FileName, mf.DownFile.FileName)
+	}
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+}
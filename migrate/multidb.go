@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// twoPhaseCommit is an internal variable that holds the state
+// of UpMultiDB
+var twoPhaseCommit = false
+
+// AllowTwoPhaseCommit enables UpMultiDB. Two-phase commits need
+// server support and leave prepared transactions behind if migrate
+// dies between prepare and commit, which then have to be resolved
+// manually.
+func AllowTwoPhaseCommit(enable bool) {
+	twoPhaseCommit = enable
+}
+
+// ErrTwoPhaseCommitNotAllowed is returned by UpMultiDB
+// unless AllowTwoPhaseCommit is enabled.
+var ErrTwoPhaseCommitNotAllowed = errors.New("two-phase commit is not allowed")
+
+// ErrTwoPhaseCommitNotSupported is returned if a driver doesn't
+// implement driver.TwoPhaseCommitDriver.
+var ErrTwoPhaseCommitNotSupported = errors.New("driver does not support two-phase commit")
+
+// UpMultiDB applies all available migrations to several databases in
+// lockstep. Each migration is prepared on all databases and committed
+// only if every prepare succeeded, otherwise it is rolled back on all
+// of them. All databases must be at the same version.
+func UpMultiDB(urls []string, migrationsPath string) error {
+	if !twoPhaseCommit {
+		return ErrTwoPhaseCommitNotAllowed
+	}
+
+	ds := make([]driver.Driver, 0, len(urls))
+	defer func() {
+		for _, d := range ds {
+			closeDriver(d)
+		}
+	}()
+
+	var files *file.MigrationFiles
+	var version uint64
+	schemes := make(map[string]bool)
+	for i, url := range urls {
+		u, err := neturl.Parse(url)
+		if err != nil {
+			return err
+		}
+
+		// the registered driver is shared, use new instances for
+		// further databases of the same kind
+		var d driver.Driver
+		if schemes[u.Scheme] {
+			d, err = driver.NewInstance(url)
+		} else {
+			d, err = driver.New(url)
+		}
+		if err != nil {
+			return err
+		}
+		schemes[u.Scheme] = true
+
+		if _, ok := d.(driver.TwoPhaseCommitDriver); !ok {
+			d.Close()
+			return ErrTwoPhaseCommitNotSupported
+		}
+		f, v, err := lockAndReadMigrationFilesAndGetVersion(d, migrationsPath, filenameRegex(d))
+		if err != nil {
+			return err
+		}
+		ds = append(ds, d)
+
+		if i > 0 && v != version {
+			return fmt.Errorf("databases are at different versions: %v and %v", version, v)
+		}
+		files, version = f, v
+	}
+	if len(ds) == 0 {
+		return nil
+	}
+
+	applyMigrationFiles, err := pendingUpFiles(ds[0], files, version)
+	if err != nil {
+		return err
+	}
+	for i, f := range applyMigrationFiles {
+		if progressFunc != nil {
+			progressFunc(i+1, len(applyMigrationFiles), f)
+		}
+		if err := migrateFileTwoPhase(ds, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateFileTwoPhase prepares f on all drivers and commits it if
+// all prepares succeeded. Otherwise the prepared ones are rolled back.
+func migrateFileTwoPhase(ds []driver.Driver, f file.File) error {
+	if err := f.ReadContent(); err != nil {
+		return err
+	}
+	if f.Direction == direction.Up {
+		f.Checksum = checksum(f.Content)
+	}
+
+	// transaction ids must be unique across the whole server,
+	// which may host several of the databases
+	ts := time.Now().UnixNano()
+	ids := make([]string, len(ds))
+	for i := range ds {
+		ids[i] = fmt.Sprintf("migrate_%v_%v_%d", f.Version, ts, i)
+	}
+	for i, d := range ds {
+		if err := d.(driver.TwoPhaseCommitDriver).PrepareMigration(f, ids[i]); err != nil {
+			for j, prepared := range ds[:i] {
+				if rollbackErr := prepared.(driver.TwoPhaseCommitDriver).RollbackPrepared(ids[j]); rollbackErr != nil {
+					logger.Printf("warning: rolling back prepared transaction %s failed: %v", ids[j], rollbackErr)
+				}
+			}
+			return err
+		}
+	}
+	for i, d := range ds {
+		if err := d.(driver.TwoPhaseCommitDriver).CommitPrepared(ids[i]); err != nil {
+			return fmt.Errorf("committing prepared transaction %s failed, resolve it manually: %v", ids[i], err)
+		}
+	}
+	return nil
+}
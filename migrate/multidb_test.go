@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestUpMultiDB(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+	urls := []string{"fake2pca://", "fake2pcb://"}
+
+	if err := UpMultiDB(urls, tmpdir); err != ErrTwoPhaseCommitNotAllowed {
+		t.Fatalf("Expected ErrTwoPhaseCommitNotAllowed, got %v", err)
+	}
+
+	AllowTwoPhaseCommit(true)
+	defer AllowTwoPhaseCommit(false)
+
+	if err := UpMultiDB([]string{"fake2pca://", "fake://"}, tmpdir); err != ErrTwoPhaseCommitNotSupported {
+		t.Errorf("Expected ErrTwoPhaseCommitNotSupported, got %v", err)
+	}
+
+	fakeTwoPhaseA.reset()
+	fakeTwoPhaseB.reset()
+	if err := UpMultiDB(urls, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*fakeTwoPhaseDriver{fakeTwoPhaseA, fakeTwoPhaseB} {
+		if version, _ := d.Version(); version != 2 {
+			t.Errorf("Expected version 2, got %v", version)
+		}
+	}
+
+	// prepare fails on the second database
+	if err := ioutil.WriteFile(path.Join(tmpdir, "003_migration3.up.sql"), []byte("FAIL"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpMultiDB(urls, tmpdir); err == nil {
+		t.Fatal("Expected prepare of version 3 to fail")
+	}
+	for _, d := range []*fakeTwoPhaseDriver{fakeTwoPhaseA, fakeTwoPhaseB} {
+		if version, _ := d.Version(); version != 2 {
+			t.Errorf("Expected version 2 after rollback, got %v", version)
+		}
+		if len(d.prepared) != 0 {
+			t.Errorf("Expected no prepared transactions, got %v", len(d.prepared))
+		}
+	}
+	if fakeTwoPhaseA.rollbacks != 1 {
+		t.Errorf("Expected 1 rollback on the first database, got %v", fakeTwoPhaseA.rollbacks)
+	}
+
+	// databases at different versions
+	fakeTwoPhaseA.reset()
+	if err := UpMultiDB(urls, tmpdir); err == nil {
+		t.Error("Expected error for databases at different versions")
+	}
+}
+
+// TestUpMultiDBPostgres requires max_prepared_transactions > 0.
+func TestUpMultiDBPostgres(t *testing.T) {
+	AllowTwoPhaseCommit(true)
+	defer AllowTwoPhaseCommit(false)
+
+	urls := []string{driverUrls[0], strings.Replace(driverUrls[0], "/template1", "/postgres", 1)}
+	dbs := make([]*sql.DB, len(urls))
+	for i, url := range urls {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(`DROP TABLE IF EXISTS yolo, yolo2, schema_migrations;`); err != nil {
+			t.Fatal(err)
+		}
+		dbs[i] = db
+	}
+
+	// the second database already has table yolo2, so its prepare fails
+	if _, err := dbs[1].Exec(`CREATE TABLE yolo2 (id int);`); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE yolo (id int);",
+		"002_migration2.up.sql": "CREATE TABLE yolo2 (id int);",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	if err := UpMultiDB(urls, tmpdir); err == nil {
+		t.Fatal("Expected prepare of version 2 to fail")
+	}
+	for i, db := range dbs {
+		var version uint64
+		if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+		if version != 1 {
+			t.Errorf("Expected version 1 in database %v, got %v", i, version)
+		}
+		var prepared int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pg_prepared_xacts`).Scan(&prepared); err != nil {
+			t.Fatal(err)
+		}
+		if prepared != 0 {
+			t.Errorf("Expected no prepared transactions in database %v, got %v", i, prepared)
+		}
+	}
+	var exists bool
+	if err := dbs[0].QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_tables WHERE tablename = 'yolo2')`).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Expected yolo2 to be rolled back in the first database")
+	}
+}
@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// failOnNotice is an internal variable that holds the state
+// of notice handling
+var failOnNotice = false
+
+// FailOnNotice makes migrations fail if the database raises a notice
+// or warning while running them, e.g. "table already exists, skipping".
+// Such migrations are rolled back. Drivers must implement
+// driver.NoticeDriver, otherwise migrations fail with ErrNoticesNotSupported.
+func FailOnNotice(enable bool) {
+	failOnNotice = enable
+}
+
+// ErrNoticesNotSupported is returned if FailOnNotice is enabled
+// and the driver doesn't implement driver.NoticeDriver.
+var ErrNoticesNotSupported = errors.New("driver does not support notices")
+
+// configureNotices passes the FailOnNotice setting to the driver.
+func configureNotices(d driver.Driver) error {
+	nd, ok := d.(driver.NoticeDriver)
+	if !ok {
+		if failOnNotice {
+			return ErrNoticesNotSupported
+		}
+		return nil
+	}
+	nd.FailOnNotice(failOnNotice)
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFailOnNotice(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "NOTICE",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeNotice.reset()
+	if err := Up("fakenotice://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeNotice.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+
+	FailOnNotice(true)
+	defer FailOnNotice(false)
+
+	fakeNotice.reset()
+	if err := Up("fakenotice://", tmpdir); err == nil {
+		t.Error("Expected notice to fail migration")
+	}
+	if version, _ := fakeNotice.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	// drivers without notices refuse to run in strict mode
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != ErrNoticesNotSupported {
+		t.Errorf("Expected ErrNoticesNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrObjectsNotSupported is returned if the driver doesn't
+// implement driver.ObjectLister.
+var ErrObjectsNotSupported = errors.New("driver does not support listing objects")
+
+// ListObjects returns all user objects in the database, e.g. to assert
+// that rolling back all migrations left nothing but the version table.
+func ListObjects(url string) ([]string, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	ol, ok := d.(driver.ObjectLister)
+	if !ok {
+		return nil, ErrObjectsNotSupported
+	}
+	return ol.ListObjects()
+}
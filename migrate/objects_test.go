@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestListObjects(t *testing.T) {
+	if _, err := ListObjects("fake://"); err != ErrObjectsNotSupported {
+		t.Errorf("Expected ErrObjectsNotSupported, got %v", err)
+	}
+}
+
+func TestListObjectsPostgres(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	// use a dedicated database, other tests leave tables behind
+	server, err := sql.Open("postgres", strings.Replace(driverUrls[0], "/template1", "/postgres", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if _, err := server.Exec(`DROP DATABASE IF EXISTS migrate_test_objects`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Exec(`CREATE DATABASE migrate_test_objects`); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Exec(`DROP DATABASE IF EXISTS migrate_test_objects`)
+	driverUrl := strings.Replace(driverUrls[0], "/template1", "/migrate_test_objects", 1)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE yolo (id serial not null primary key); CREATE VIEW yolo_view AS SELECT id FROM yolo;",
+		"001_migration1.down.sql": "DROP VIEW yolo_view; DROP TABLE yolo;",
+		"002_migration2.up.sql":   "CREATE FUNCTION yolo_fn() RETURNS int AS 'SELECT 1' LANGUAGE sql;",
+		"002_migration2.down.sql": "DROP FUNCTION yolo_fn();",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	if err := Up(driverUrl, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := ListObjects(driverUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"function public.yolo_fn", "sequence public.yolo_id_seq", "table public.schema_migrations", "table public.yolo", "view public.yolo_view"}
+	if strings.Join(objects, ",") != strings.Join(expect, ",") {
+		t.Errorf("Expected objects %v, got %v", expect, objects)
+	}
+
+	if err := Down(driverUrl, tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	objects, err = ListObjects(driverUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0] != "table public.schema_migrations" {
+		t.Errorf("Expected only the version table to remain, got %v", objects)
+	}
+}
@@ -0,0 +1,57 @@
+// Package oteltrace adapts an OpenTelemetry tracer for migrate.SetTracer.
+//
+//	migrate.SetTracer(oteltrace.New(otel.Tracer("migrate")))
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chr4/migrate/migrate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a migrate.Tracer starting spans with tracer.
+func New(tracer trace.Tracer) migrate.Tracer {
+	return &otelTracer{tracer: tracer}
+}
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+func (t *otelTracer) Start(ctx context.Context, name string, attributes ...migrate.Attribute) (context.Context, migrate.Span) {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+	for _, a := range attributes {
+		kvs = append(kvs, keyValue(a))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(kvs...))
+	return ctx, &otelSpan{span: span}
+}
+
+// keyValue converts the string and int64 values of migrate.Attribute
+func keyValue(a migrate.Attribute) attribute.KeyValue {
+	switch v := a.Value.(type) {
+	case string:
+		return attribute.String(a.Key, v)
+	case int64:
+		return attribute.Int64(a.Key, v)
+	default:
+		return attribute.String(a.Key, fmt.Sprint(v))
+	}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"sync"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// UpParallel applies all available migrations to several databases like
+// Up, e.g. to a fleet of shards with identical schemas. At most
+// concurrency databases are migrated at once, each with its own driver
+// instance and migration lock. Unlike UpMultiDB the databases are
+// migrated independently, a failure doesn't affect the others. The
+// returned map holds the error of each url, nil if it was migrated.
+// Callbacks like SetProgressFunc are called concurrently.
+func UpParallel(urls []string, migrationsPath string, concurrency int) map[string]error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error, len(urls))
+	sem := make(chan struct{}, concurrency)
+	for _, url := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(url string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d, err := driver.NewInstance(url)
+			if err == nil {
+				_, err = upWithResult(d, url, migrationsPath)
+			}
+			mu.Lock()
+			errs[url] = err
+			mu.Unlock()
+		}(url)
+	}
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestUpParallel(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "CREATE TABLE yolo (id INTEGER PRIMARY KEY);",
+		"002_migration2.up.sql": "ALTER TABLE yolo ADD COLUMN name TEXT;",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	urls := make([]string, 0)
+	for _, shard := range []string{"shard1", "shard2", "shard3", "shard4", "shard5"} {
+		urls = append(urls, "sqlite3://"+path.Join(tmpdir, shard+".db"))
+	}
+
+	// shard3 already has the table, so its first migration fails
+	connection, err := sql.Open("sqlite3", path.Join(tmpdir, "shard3.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Exec("CREATE TABLE yolo (id INTEGER PRIMARY KEY);"); err != nil {
+		t.Fatal(err)
+	}
+	connection.Close()
+
+	urls = append(urls, "unknown://shard6")
+	errs := UpParallel(urls, tmpdir, 2)
+	if len(errs) != len(urls) {
+		t.Fatalf("Expected results for %v urls, got %v", len(urls), errs)
+	}
+
+	for _, url := range urls {
+		err := errs[url]
+		switch {
+		case strings.Contains(url, "shard3"):
+			if err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Errorf("Expected %s to fail creating the table, got %v", url, err)
+			}
+			if version, err := Version(url, tmpdir); err != nil || version != 0 {
+				t.Errorf("Expected %s at version 0, got %v (%v)", url, version, err)
+			}
+		case strings.Contains(url, "shard6"):
+			if err == nil {
+				t.Errorf("Expected %s to fail", url)
+			}
+		default:
+			if err != nil {
+				t.Errorf("Expected %s to be migrated, got %v", url, err)
+			}
+			if version, err := Version(url, tmpdir); err != nil || version != 2 {
+				t.Errorf("Expected %s at version 2, got %v (%v)", url, version, err)
+			}
+		}
+	}
+}
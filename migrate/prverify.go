@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// PRVerify checks that all migrations can be applied and rolled back,
+// e.g. in CI before merging a pull request. It runs all pending up
+// migrations, then all down migrations to version 0, and finally
+// migrates up to the version the database had before. A failure names
+// the phase (up, down or restore) and the file, the database is left
+// where the failing migration stopped. It requires AllowDown and should
+// be run against a throwaway database.
+func PRVerify(url, migrationsPath string) (err error) {
+	url, err = checkAllowDown(url)
+	if err != nil {
+		return
+	}
+
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return
+	}
+	defer closeDriver(d)
+
+	upFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return
+	}
+	if err = verifyPhase(d, "up", upFiles); err != nil {
+		return
+	}
+
+	latest, err := d.Version()
+	if err != nil {
+		return
+	}
+	downFiles, err := allDownFiles(files, latest)
+	if err != nil {
+		return
+	}
+	if err = verifyPhase(d, "down", downFiles); err != nil {
+		return
+	}
+
+	allUpFiles, err := pendingUpFiles(d, files, 0)
+	if err != nil {
+		return
+	}
+	restoreFiles := make(file.Files, 0)
+	for _, f := range allUpFiles {
+		if f.Version <= version {
+			restoreFiles = append(restoreFiles, f)
+		}
+	}
+	err = verifyPhase(d, "restore", restoreFiles)
+	return
+}
+
+// verifyPhase applies files in order and annotates a failure
+// with the phase and the failing file
+func verifyPhase(d driver.Driver, phase string, files file.Files) error {
+	for i, f := range files {
+		if progressFunc != nil {
+			progressFunc(i+1, len(files), f)
+		}
+		if err := migrateFile(d, f); err != nil {
+			return fmt.Errorf("%s phase failed at %s (%d of %d files applied): %v", phase, f.FileName, i, len(files), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPRVerify(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE t1 ();",
+		"001_migration1.down.sql": "DROP TABLE t1;",
+		"002_migration2.up.sql":   "CREATE TABLE t2 ();",
+		"002_migration2.down.sql": "DROP TABLE t2;",
+		"003_migration3.up.sql":   "CREATE TABLE t3 ();",
+		"003_migration3.down.sql": "DROP TABLE t3;",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := PRVerify("fake://", tmpdir); err != ErrDownNotAllowed {
+		t.Fatalf("Expected ErrDownNotAllowed, got %v", err)
+	}
+
+	AllowDown(true)
+	defer AllowDown(false)
+
+	if err := Migrate("fake://", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+	fake.applied = nil
+	if err := PRVerify("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 1 {
+		t.Errorf("Expected original version 1 to be restored, got %v", version)
+	}
+
+	// up 2, 3, down 3, 2, 1, restore 1
+	expected := []string{
+		"002_migration2.up.sql", "003_migration3.up.sql",
+		"003_migration3.down.sql", "002_migration2.down.sql", "001_migration1.down.sql",
+		"001_migration1.up.sql",
+	}
+	if len(fake.applied) != len(expected) {
+		t.Fatalf("Expected %v files to be applied, got %v", len(expected), fake.applied)
+	}
+	for i, f := range fake.applied {
+		if f.FileName != expected[i] {
+			t.Errorf("Expected %v at position %v, got %v", expected[i], i, f.FileName)
+		}
+	}
+}
+
+func TestPRVerifyBrokenDown(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "CREATE TABLE t1 ();",
+		"001_migration1.down.sql": "DROP TABLE t1;",
+		"002_migration2.up.sql":   "CREATE TABLE t2 ();",
+		"002_migration2.down.sql": "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	AllowDown(true)
+	defer AllowDown(false)
+
+	fake.reset()
+	err := PRVerify("fake://", tmpdir)
+	if err == nil {
+		t.Fatal("Expected broken down migration to fail")
+	}
+	if !strings.Contains(err.Error(), "down phase failed at 002_migration2.down.sql") {
+		t.Errorf("Expected error to name down phase and file, got %v", err)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Errorf("Expected version 2 after failure, got %v", version)
+	}
+}
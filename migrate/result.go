@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// Result describes a migration run.
+type Result struct {
+	// StartVersion is the version before the run
+	StartVersion uint64
+
+	// Version is the version after the run
+	Version uint64
+
+	// Files holds the files migrated in order, including a failed one
+	Files []FileResult
+
+	// Duration is the time the whole run took
+	Duration time.Duration
+
+	// Err is the error which stopped the run, nil on success
+	Err error
+}
+
+// FileResult describes the migration of a single file.
+type FileResult struct {
+	File     file.File
+	Duration time.Duration
+
+	// Statements holds the timings of the file's statements if the
+	// driver runs them one by one, see driver.StatementTimer
+	Statements []StatementTiming
+
+	// Plans holds the query plans of the file's statements if it has
+	// a "-- migrate:explain" directive, see driver.Explainer
+	Plans []StatementPlan
+
+	// Err is the error returned by the driver, nil on success
+	Err error
+}
+
+// StatementTiming is the time a single statement of a file took.
+type StatementTiming struct {
+	// Index is the 0-based index of the statement in the file
+	Index    int
+	Duration time.Duration
+}
+
+// StatementPlan is the query plan of a single statement of a file.
+type StatementPlan struct {
+	// Index is the 0-based index of the statement in the file
+	Index int
+
+	// Plan is the output of EXPLAIN, empty if the statement
+	// can't be explained
+	Plan string
+
+	// Reason tells why a statement wasn't explained, e.g. for DDL
+	Reason string
+}
+
+// runWithResult calls fn with a new result for a run starting at
+// version and completes the result once fn returned.
+func runWithResult(d driver.Driver, version uint64, fn func(result *Result) error) *Result {
+	result := &Result{StartVersion: version, Version: version}
+	start := time.Now()
+	result.Err = fn(result)
+	result.Duration = time.Since(start)
+	v, err := d.Version()
+	switch {
+	case err == nil:
+		result.Version = v
+	case result.Err == nil:
+		result.Err = err
+	default:
+		result.Err = fmt.Errorf("%v\nreading version failed: %v", result.Err, err)
+	}
+	return result
+}
+
+// migrateFile migrates f like the package level migrateFileContext
+// and records its outcome. A nil result records nothing.
+func (result *Result) migrateFile(ctx context.Context, d driver.Driver, f file.File) error {
+	if result == nil {
+		return migrateFileContext(ctx, d, f)
+	}
+
+	statements := make([]StatementTiming, 0)
+	if st, ok := d.(driver.StatementTimer); ok {
+		st.OnStatement(func(f file.File, index int, elapsed time.Duration) {
+			// drop the timings of failed attempts of retrying drivers
+			if index < len(statements) {
+				statements = statements[:index]
+			}
+			statements = append(statements, StatementTiming{Index: index, Duration: elapsed})
+		})
+		defer st.OnStatement(nil)
+	}
+
+	var plans []StatementPlan
+	if e, ok := d.(driver.Explainer); ok {
+		e.OnExplain(func(f file.File, index int, plan, reason string) {
+			// drop the plans of failed attempts of retrying drivers
+			if index < len(plans) {
+				plans = plans[:index]
+			}
+			plans = append(plans, StatementPlan{Index: index, Plan: plan, Reason: reason})
+		})
+		defer e.OnExplain(nil)
+	}
+
+	start := time.Now()
+	err := migrateFileContext(ctx, d, f)
+	result.Files = append(result.Files, FileResult{File: f, Duration: time.Since(start), Statements: statements, Plans: plans, Err: err})
+	return err
+}
+
+// Summary returns a one-line description of the run for humans, e.g.
+// "Applied 3 migrations (5→8) in 1.2s" or "No migrations to apply".
+// It includes the error of a failed run.
+func (result *Result) Summary() string {
+	applied := 0
+	var failed *FileResult
+	for i := range result.Files {
+		if result.Files[i].Err != nil {
+			failed = &result.Files[i]
+			continue
+		}
+		applied++
+	}
+
+	if applied == 0 && result.Err == nil {
+		return "No migrations to apply"
+	}
+
+	verb := "Applied"
+	if len(result.Files) > 0 && result.Files[0].File.Direction == direction.Down {
+		verb = "Rolled back"
+	}
+	noun := "migrations"
+	if applied == 1 {
+		noun = "migration"
+	}
+	summary := fmt.Sprintf("%s %d %s (%d→%d) in %v", verb, applied, noun, result.StartVersion, result.Version, roundDuration(result.Duration))
+	switch {
+	case failed != nil:
+		summary += fmt.Sprintf(", %s failed: %v", failed.File.FileName, failed.Err)
+	case result.Err != nil:
+		summary += fmt.Sprintf(", failed: %v", result.Err)
+	}
+	return summary
+}
+
+// roundDuration rounds d for display, to 100ms from a second on
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
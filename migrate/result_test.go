@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestUpWithResult(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1; SELECT 2;\nSELECT 3;",
+		"002_migration2.up.sql": "SELECT 1; -- RETRY\nSELECT 2",
+		"003_migration3.up.sql": "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeStatement.reset()
+	result, err := UpWithResult("fakestatement://", tmpdir)
+	if err == nil || result == nil || result.Err != err {
+		t.Fatalf("Expected version 3 to fail, got %v %v", result, err)
+	}
+	if result.StartVersion != 0 || result.Version != 2 || len(result.Files) != 3 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if result.Files[2].Err == nil || result.Files[0].Err != nil {
+		t.Errorf("Expected only version 3 to fail, got %+v", result.Files)
+	}
+
+	for i, expect := range []int{3, 2} {
+		statements := result.Files[i].Statements
+		if len(statements) != expect {
+			t.Errorf("Expected %v statement timings for version %v, got %v", expect, i+1, statements)
+			continue
+		}
+		var total time.Duration
+		for j, s := range statements {
+			if s.Index != j || s.Duration < fakeStatement.delay {
+				t.Errorf("Unexpected timing %+v of statement %v", s, j)
+			}
+			total += s.Duration
+		}
+		if result.Files[i].Duration < total {
+			t.Errorf("Expected file duration %v to cover its statements %v", result.Files[i].Duration, total)
+		}
+	}
+
+	// drivers running whole files report no statements
+	tmpdir2 := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1; SELECT 2;",
+	})
+	defer os.RemoveAll(tmpdir2)
+	fake.reset()
+	result, err = UpWithResult("fake://", tmpdir2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 || len(result.Files[0].Statements) != 0 {
+		t.Errorf("Expected no statement timings, got %+v", result.Files)
+	}
+}
+
+func TestUpWithResultPlans(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "-- migrate:explain\nCREATE TABLE yolo (); SELECT 1 -- RETRY\n",
+		"002_migration2.up.sql": "SELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeStatement.reset()
+	result, err := UpWithResult("fakestatement://", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	expect := []StatementPlan{
+		{Index: 0, Reason: "not a SELECT"},
+		{Index: 1, Plan: "Result (actual rows=1)"},
+	}
+	plans := result.Files[0].Plans
+	if len(plans) != len(expect) {
+		t.Fatalf("Expected plans %+v, got %+v", expect, plans)
+	}
+	for i := range expect {
+		if plans[i] != expect[i] {
+			t.Errorf("Expected plan %+v, got %+v", expect[i], plans[i])
+		}
+	}
+
+	// files without explain directive have no plans
+	if len(result.Files[1].Plans) != 0 {
+		t.Errorf("Expected no plans for version 2, got %+v", result.Files[1].Plans)
+	}
+}
+
+// fakeVersionErrDriver fails to read its version
+type fakeVersionErrDriver struct {
+	fakeDriver
+}
+
+func (driver *fakeVersionErrDriver) Version() (uint64, error) {
+	return 0, errors.New("version table is gone")
+}
+
+func TestRunWithResultVersionError(t *testing.T) {
+	d := &fakeVersionErrDriver{}
+	d.reset()
+
+	result := runWithResult(d, 1, func(result *Result) error { return nil })
+	if result.Err == nil || result.Err.Error() != "version table is gone" {
+		t.Errorf("Expected the version error, got %v", result.Err)
+	}
+
+	result = runWithResult(d, 1, func(result *Result) error { return errors.New("migration failed") })
+	if result.Err == nil || result.Err.Error() != "migration failed\nreading version failed: version table is gone" {
+		t.Errorf("Expected both errors, got %v", result.Err)
+	}
+	if result.Version != 1 {
+		t.Errorf("Expected version 1, got %v", result.Version)
+	}
+}
+
+func TestResultSummary(t *testing.T) {
+	up := func(version uint64) file.File {
+		return file.File{FileName: fmt.Sprintf("%03d_migration.up.sql", version), Version: version, Direction: direction.Up}
+	}
+	down := func(version uint64) file.File {
+		return file.File{FileName: fmt.Sprintf("%03d_migration.down.sql", version), Version: version, Direction: direction.Down}
+	}
+
+	var tests = []struct {
+		result Result
+		expect string
+	}{
+		{Result{StartVersion: 8, Version: 8, Duration: 3 * time.Millisecond}, "No migrations to apply"},
+		{Result{StartVersion: 5, Version: 8, Duration: 1234 * time.Millisecond, Files: []FileResult{{File: up(6)}, {File: up(7)}, {File: up(8)}}},
+			"Applied 3 migrations (5→8) in 1.2s"},
+		{Result{StartVersion: 0, Version: 1, Duration: 12345 * time.Microsecond, Files: []FileResult{{File: up(1)}}},
+			"Applied 1 migration (0→1) in 12ms"},
+		{Result{StartVersion: 8, Version: 6, Duration: 2 * time.Second, Files: []FileResult{{File: down(8)}, {File: down(7)}}},
+			"Rolled back 2 migrations (8→6) in 2s"},
+		{Result{StartVersion: 5, Version: 6, Duration: 500 * time.Millisecond, Err: errors.New("syntax error"), Files: []FileResult{{File: up(6)}, {File: up(7), Err: errors.New("syntax error")}}},
+			"Applied 1 migration (5→6) in 500ms, 007_migration.up.sql failed: syntax error"},
+		{Result{StartVersion: 5, Version: 5, Err: errors.New("syntax error"), Files: []FileResult{{File: up(6), Err: errors.New("syntax error")}}},
+			"Applied 0 migrations (5→5) in 0s, 006_migration.up.sql failed: syntax error"},
+		{Result{StartVersion: 5, Version: 6, Err: errors.New("post up check returned rows"), Files: []FileResult{{File: up(6)}}},
+			"Applied 1 migration (5→6) in 0s, failed: post up check returned rows"},
+	}
+
+	for _, test := range tests {
+		if summary := test.result.Summary(); summary != test.expect {
+			t.Errorf("Expected summary %q, got %q", test.expect, summary)
+		}
+	}
+}
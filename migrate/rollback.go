@@ -0,0 +1,238 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url" // alias to allow `url string` func signature
+	"sort"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// autoRollback is an internal variable that holds the state
+// of automatic rollback in Up
+var autoRollback = false
+
+// AutoRollback makes Up roll back all migrations applied by the
+// same run if a migration fails, using their down migrations.
+// On drivers without transactional DDL the failed migration itself
+// may be partially applied and can't be rolled back; a warning
+// is logged in that case.
+func AutoRollback(enable bool) {
+	autoRollback = enable
+}
+
+// ErrNonTransactionalDDL is returned by UpAtomic for drivers
+// without transactional DDL.
+var ErrNonTransactionalDDL = errors.New("driver does not support transactional DDL")
+
+// skipMissingDown is an internal variable that holds the state
+// of down migration enforcement
+var skipMissingDown = false
+
+// SkipMissingDown makes rollbacks skip versions without a down
+// migration, e.g. irreversible migrations parsed with file.TreatBareAsUp.
+// Their versions are removed without running any SQL, which requires
+// drivers implementing driver.VersionRemover. By default such
+// rollbacks fail before anything is rolled back.
+func SkipMissingDown(enable bool) {
+	skipMissingDown = enable
+}
+
+// allowDown is an internal variable that holds the state
+// of the rollback protection
+var allowDown = false
+
+// AllowDown allows Down, Reset and RollbackLast, which refuse to run by
+// default to protect production databases from accidental rollbacks.
+// Adding x-allow-down=true to the url allows them for a single call.
+func AllowDown(allow bool) {
+	allowDown = allow
+}
+
+// ErrDownNotAllowed is returned by Down, Reset and RollbackLast
+// unless rolling back has been allowed, see AllowDown.
+var ErrDownNotAllowed = errors.New("rolling back migrations is not allowed, use AllowDown(true) or x-allow-down=true")
+
+// checkAllowDown returns ErrDownNotAllowed unless rolling back is
+// allowed. The x-allow-down parameter is removed from the returned
+// url, since drivers don't know it.
+func checkAllowDown(url string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	allowed := allowDown
+	if _, ok := query["x-allow-down"]; ok {
+		allowed = allowed || query.Get("x-allow-down") == "true"
+		query.Del("x-allow-down")
+		u.RawQuery = query.Encode()
+		url = u.String()
+	}
+	if !allowed {
+		return "", ErrDownNotAllowed
+	}
+	return url, nil
+}
+
+// UpAtomic applies all available migrations or none at all. If a
+// migration fails, all migrations applied by this run are rolled back.
+// It refuses to run on drivers without transactional DDL, since the
+// failed migration could be left partially applied.
+func UpAtomic(url, migrationsPath string) error {
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	if !driver.TransactionalDDL(d) {
+		return ErrNonTransactionalDDL
+	}
+
+	version, err = applyBaseline(d, version)
+	if err != nil {
+		return err
+	}
+
+	applyMigrationFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return err
+	}
+	if err := migrateUpFiles(d, files, applyMigrationFiles, true, nil); err != nil {
+		return err
+	}
+	return runPostUpCheck(d)
+}
+
+// RollbackLast rolls back the n most recently applied versions, as
+// reported by the driver, in descending order. Unlike Migrate with a
+// negative n, it also handles non-contiguous versions, e.g. after
+// cherry-picking. Nothing is rolled back if a down migration is missing,
+// unless SkipMissingDown is enabled. It requires AllowDown.
+func RollbackLast(url, migrationsPath string, n int) error {
+	url, err := checkAllowDown(url)
+	if err != nil {
+		return err
+	}
+	d, files, _, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	vd, ok := d.(driver.VersionsDriver)
+	if !ok {
+		return ErrVersionsNotSupported
+	}
+	applied, err := vd.Versions()
+	if err != nil {
+		return err
+	}
+	if n > len(applied) {
+		n = len(applied)
+	}
+
+	downFiles := make(file.Files, 0, n)
+	for i := len(applied) - 1; i >= len(applied)-n; i-- {
+		f, err := downFile(files, applied[i].Version)
+		if err != nil {
+			return err
+		}
+		downFiles = append(downFiles, *f)
+	}
+	return migrateFiles(d, downFiles)
+}
+
+// migrateUpFiles applies the given files, see migrateFiles. If rollback
+// is true and a file fails, the files applied so far are rolled back in
+// reverse order using the down files of files.
+// No further files are started once the run timeout has passed.
+// The applied files are recorded in result, unless it is nil.
+func migrateUpFiles(d driver.Driver, files *file.MigrationFiles, upFiles file.Files, rollback bool, result *Result) error {
+	ctx, cancel := runContext()
+	defer cancel()
+	applied := 0
+	for i, f := range upFiles {
+		if ctx.Err() != nil {
+			return runTimeoutError(i, len(upFiles))
+		}
+		if progressFunc != nil {
+			progressFunc(i+1, len(upFiles), f)
+		}
+		if err := result.migrateFile(ctx, d, f); err != nil {
+			if ctx.Err() != nil {
+				err = runTimeoutError(i, len(upFiles))
+			}
+			if !rollback {
+				return err
+			}
+			if !driver.TransactionalDDL(d) {
+				logger.Printf("warning: %T does not support transactional DDL, %s may be partially applied", d, f.FileName)
+			}
+			if rollbackErr := rollbackFiles(d, files, upFiles[:applied]); rollbackErr != nil {
+				return fmt.Errorf("%v\nrollback failed: %v", err, rollbackErr)
+			}
+			return err
+		}
+		applied += 1
+	}
+	return nil
+}
+
+// downFile returns the down file of version. If there is none and
+// SkipMissingDown is enabled, it returns a file.File.VersionOnly file
+// which only removes the version.
+func downFile(files *file.MigrationFiles, version uint64) (*file.File, error) {
+	mf := files.Find(version)
+	if mf != nil && mf.DownFile != nil {
+		return mf.DownFile, nil
+	}
+	if !skipMissingDown {
+		return nil, fmt.Errorf("no down migration for version %v", version)
+	}
+	logger.Printf("warning: version %v has no down migration, removing it without running SQL", version)
+	return &file.File{
+		FileName:    fmt.Sprintf("%v (no down migration)", version),
+		Version:     version,
+		Direction:   direction.Down,
+		VersionOnly: true,
+	}, nil
+}
+
+// allDownFiles returns the down files of all versions up to and
+// including version in descending order. files keeps its order.
+func allDownFiles(files *file.MigrationFiles, version uint64) (file.Files, error) {
+	sorted := make(file.MigrationFiles, len(*files))
+	copy(sorted, *files)
+	sort.Sort(sort.Reverse(sorted))
+	downFiles := make(file.Files, 0)
+	for _, mf := range sorted {
+		if mf.Version > version {
+			continue
+		}
+		f, err := downFile(files, mf.Version)
+		if err != nil {
+			return nil, err
+		}
+		downFiles = append(downFiles, *f)
+	}
+	return downFiles, nil
+}
+
+// rollbackFiles runs the down migrations of the given up files in reverse order.
+func rollbackFiles(d driver.Driver, files *file.MigrationFiles, upFiles file.Files) error {
+	for i := len(upFiles) - 1; i >= 0; i-- {
+		f, err := downFile(files, upFiles[i].Version)
+		if err != nil {
+			return err
+		}
+		if err := migrateFile(d, *f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
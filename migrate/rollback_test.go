@@ -0,0 +1,271 @@
+package migrate
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+func TestUpAtomic(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "FAIL",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeTx.reset()
+	if err := UpAtomic("faketx://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+	if version, _ := fakeTx.Version(); version != 0 {
+		t.Errorf("Expected version 0 after rollback, got %v", version)
+	}
+
+	fakeNonTx.reset()
+	if err := UpAtomic("fakenontx://", tmpdir); err != ErrNonTransactionalDDL {
+		t.Errorf("Expected ErrNonTransactionalDDL, got %v", err)
+	}
+	if len(fakeNonTx.applied) != 0 {
+		t.Errorf("Expected no applied files, got %v", len(fakeNonTx.applied))
+	}
+}
+
+func TestAutoRollback(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	// without automatic rollback
+	fakeTx.reset()
+	if err := Up("faketx://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+	if version, _ := fakeTx.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+
+	AutoRollback(true)
+	defer AutoRollback(false)
+
+	fakeTx.reset()
+	if err := Up("faketx://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+	if version, _ := fakeTx.Version(); version != 0 {
+		t.Errorf("Expected version 0 after rollback, got %v", version)
+	}
+	if strings.Contains(buf.String(), "transactional DDL") {
+		t.Errorf("Expected no warning for transactional driver, got %q", buf.String())
+	}
+
+	fakeNonTx.reset()
+	if err := Up("fakenontx://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+	if version, _ := fakeNonTx.Version(); version != 0 {
+		t.Errorf("Expected version 0 after rollback, got %v", version)
+	}
+	if !strings.Contains(buf.String(), "transactional DDL") {
+		t.Errorf("Expected warning for non-transactional driver, got %q", buf.String())
+	}
+}
+
+func TestRollbackLast(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+		"005_migration5.up.sql":   "SELECT 1",
+		"005_migration5.down.sql": "SELECT 1",
+		"007_migration7.up.sql":   "SELECT 1",
+		"007_migration7.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	// applied versions 1, 3 and 7
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	delete(fake.versions, 5)
+	fake.applied = nil
+
+	if err := RollbackLast("fake://", tmpdir, 2); err != nil {
+		t.Fatal(err)
+	}
+	expect := []uint64{7, 3}
+	if len(fake.applied) != len(expect) {
+		t.Fatalf("Expected %v rolled back files, got %v", len(expect), len(fake.applied))
+	}
+	for i, version := range expect {
+		if fake.applied[i].Version != version || fake.applied[i].Direction != direction.Down {
+			t.Errorf("Expected down file for version %v at index %v, got %v", version, i, fake.applied[i].FileName)
+		}
+	}
+	if version, _ := fake.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	// n larger than the number of applied versions
+	if err := RollbackLast("fake://", tmpdir, 5); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+
+	// missing down migration rolls back nothing
+	if err := os.Remove(path.Join(tmpdir, "007_migration7.down.sql")); err != nil {
+		t.Fatal(err)
+	}
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	fake.applied = nil
+	if err := RollbackLast("fake://", tmpdir, 2); err == nil {
+		t.Error("Expected error for missing down migration")
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected no rolled back files, got %v", len(fake.applied))
+	}
+}
+
+func TestSkipMissingDown(t *testing.T) {
+	AllowDown(true)
+	defer AllowDown(false)
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"003_migration3.up.sql":   "SELECT 1",
+		"003_migration3.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	fake.applied = nil
+
+	err := Down("fake://", tmpdir)
+	if err == nil || err.Error() != "no down migration for version 2" {
+		t.Fatalf("Expected error for missing down migration, got %v", err)
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected nothing to be rolled back, got %v", len(fake.applied))
+	}
+
+	SkipMissingDown(true)
+	defer SkipMissingDown(false)
+
+	if err := Down("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+	if len(fake.applied) != 2 || len(fake.removed) != 1 || fake.removed[0] != 2 {
+		t.Errorf("Expected version 2 to be removed without running a migration, got %v and %v", fake.applied, fake.removed)
+	}
+}
+
+func TestAllowDown(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	// blocked by default
+	if err := Down("fake://", tmpdir); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed for Down, got %v", err)
+	}
+	if err := Reset("fake://", tmpdir); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed for Reset, got %v", err)
+	}
+	if err := RollbackLast("fake://", tmpdir, 1); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed for RollbackLast, got %v", err)
+	}
+	if err := Down("fake://?x-allow-down=false", tmpdir); err != ErrDownNotAllowed {
+		t.Errorf("Expected ErrDownNotAllowed for x-allow-down=false, got %v", err)
+	}
+	if version, _ := fake.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+
+	// allowed by url
+	if err := RollbackLast("fake://?x-allow-down=true", tmpdir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+
+	// allowed globally
+	AllowDown(true)
+	defer AllowDown(false)
+	if err := Down("fake://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fake.Version(); version != 0 {
+		t.Errorf("Expected version 0, got %v", version)
+	}
+}
+
+func TestAllDownFilesKeepsOrder(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+		"002_migration2.down.sql": "SELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	files, err := readMigrationFiles(tmpdir, file.FilenameRegex("sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	downFiles, err := allDownFiles(&files, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downFiles) != 2 || downFiles[0].Version != 2 || downFiles[1].Version != 1 {
+		t.Errorf("Expected down files of versions 2 and 1, got %v", downFiles)
+	}
+	if files[0].Version != 1 || files[1].Version != 2 {
+		t.Errorf("Expected files to stay in ascending order, got versions %v and %v", files[0].Version, files[1].Version)
+	}
+}
@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	neturl "net/url" // alias to allow `url string` func signature
+	"strings"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// continueOnSchemaError is an internal variable that holds the
+// state of error handling in UpAllSchemas
+var continueOnSchemaError = false
+
+// ContinueOnSchemaError makes UpAllSchemas migrate the remaining
+// schemas after a schema failed. By default it stops at the first failure.
+func ContinueOnSchemaError(enable bool) {
+	continueOnSchemaError = enable
+}
+
+// ErrSchemasNotSupported is returned if the driver doesn't
+// implement driver.SchemaLister.
+var ErrSchemasNotSupported = errors.New("driver does not support schemas")
+
+// UpAllSchemas applies all available migrations to every schema matching
+// the SQL LIKE pattern, e.g. "tenant_%". Each schema is migrated with the
+// search_path set to the schema, so each schema holds its own version table.
+// The returned error lists all failed schemas.
+func UpAllSchemas(url, migrationsPath, schemaPattern string) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+	sl, ok := d.(driver.SchemaLister)
+	if !ok {
+		d.Close()
+		return ErrSchemasNotSupported
+	}
+	schemas, err := sl.Schemas(schemaPattern)
+	d.Close()
+	if err != nil {
+		return err
+	}
+
+	failures := make([]string, 0)
+	for _, schema := range schemas {
+		schemaURL, err := withSearchPath(url, schema)
+		if err == nil {
+			err = Up(schemaURL, migrationsPath)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("schema %s: %v", schema, err))
+			if !continueOnSchemaError {
+				break
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// withSearchPath sets the search_path option of the url to schema.
+func withSearchPath(url, schema string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func TestWithSearchPath(t *testing.T) {
+	url, err := withSearchPath("postgres://user@host:5432/db?sslmode=disable", "tenant_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "postgres://user@host:5432/db?search_path=tenant_a&sslmode=disable" {
+		t.Errorf("Unexpected url %v", url)
+	}
+}
+
+func TestUpAllSchemas(t *testing.T) {
+	defer ContinueOnSchemaError(false)
+
+	db, err := sql.Open("postgres", driverUrls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	prepare := func() {
+		// tenant_b already has table foo, so its migration fails
+		if _, err := db.Exec(`
+			DROP SCHEMA IF EXISTS tenant_a CASCADE;
+			DROP SCHEMA IF EXISTS tenant_b CASCADE;
+			DROP SCHEMA IF EXISTS tenant_c CASCADE;
+			CREATE SCHEMA tenant_a;
+			CREATE SCHEMA tenant_b;
+			CREATE SCHEMA tenant_c;
+			CREATE TABLE tenant_b.foo (id int);`); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_create_foo.up.sql": "CREATE TABLE foo (id int);",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	versions := func() map[string]uint64 {
+		versions := make(map[string]uint64)
+		for _, schema := range []string{"tenant_a", "tenant_b", "tenant_c"} {
+			var version uint64
+			db.QueryRow(`SELECT MAX(version) FROM ` + schema + `.schema_migrations`).Scan(&version)
+			versions[schema] = version
+		}
+		return versions
+	}
+
+	// stop at first failure
+	prepare()
+	if err := UpAllSchemas(driverUrls[0], tmpdir, "tenant_%"); err == nil {
+		t.Error("Expected tenant_b to fail")
+	}
+	if v := versions(); v["tenant_a"] != 1 || v["tenant_b"] != 0 || v["tenant_c"] != 0 {
+		t.Errorf("Unexpected versions %v", v)
+	}
+
+	// continue after failure
+	ContinueOnSchemaError(true)
+	prepare()
+	if err := UpAllSchemas(driverUrls[0], tmpdir, "tenant_%"); err == nil {
+		t.Error("Expected tenant_b to fail")
+	}
+	if v := versions(); v["tenant_a"] != 1 || v["tenant_b"] != 0 || v["tenant_c"] != 1 {
+		t.Errorf("Unexpected versions %v", v)
+	}
+}
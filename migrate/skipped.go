@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+)
+
+// SkipReason tells why Up wouldn't run a migration.
+type SkipReason string
+
+const (
+	// SkipFiltered is the reason of migrations excluded by SetNameFilter
+	SkipFiltered SkipReason = "filtered"
+
+	// SkipIgnored is the reason of unapplied migrations with a version
+	// lower than the current version, which Up never applies
+	SkipIgnored SkipReason = "ignored"
+
+	// SkipGuardFailed is the reason of migrations whose guard directive
+	// currently doesn't permit them to run
+	SkipGuardFailed SkipReason = "guard-failed"
+
+	// SkipAlreadyApplied is the reason of applied migrations
+	SkipAlreadyApplied SkipReason = "already-applied"
+)
+
+// SkippedMigration is an up migration Up wouldn't run.
+type SkippedMigration struct {
+	File   file.File
+	Reason SkipReason
+}
+
+// SkippedMigrations returns the up migrations Up wouldn't run and why,
+// in order of their versions. Migrations with a version lower than the
+// current version are only reported as ignored if the driver implements
+// driver.VersionsDriver, otherwise they're assumed to be applied. Guards
+// are evaluated against the current database, so they may change their
+// result once earlier migrations ran. It doesn't acquire the migration
+// lock.
+func SkippedMigrations(url, migrationsPath string) ([]SkippedMigration, error) {
+	d, err := driver.New(url)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	files, err := readUnfilteredMigrationFiles(migrationsPath, filenameRegex(d))
+	if err != nil {
+		return nil, err
+	}
+	version, err := d.Version()
+	if err != nil {
+		return nil, err
+	}
+	var applied map[uint64]bool
+	if vd, ok := d.(driver.VersionsDriver); ok {
+		versions, err := vd.Versions()
+		if err != nil {
+			return nil, err
+		}
+		applied = make(map[uint64]bool, len(versions))
+		for _, v := range versions {
+			applied[v.Version] = true
+		}
+	}
+
+	skipped := make([]SkippedMigration, 0)
+	for _, mf := range files {
+		if mf.UpFile == nil {
+			continue
+		}
+		f := *mf.UpFile
+		reason, err := skipReason(d, f, version, applied)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			skipped = append(skipped, SkippedMigration{File: f, Reason: reason})
+		}
+	}
+	return skipped, nil
+}
+
+// skipReason returns why Up wouldn't run f, or an empty reason if it
+// would. applied is nil if the driver doesn't report applied versions.
+func skipReason(d driver.Driver, f file.File, version uint64, applied map[uint64]bool) (SkipReason, error) {
+	switch {
+	case nameFilter != nil && !nameFilter(f.Name):
+		return SkipFiltered, nil
+	case applied[f.Version] || (applied == nil && f.Version <= version):
+		return SkipAlreadyApplied, nil
+	case f.Version <= version:
+		return SkipIgnored, nil
+	}
+
+	if err := f.ReadContent(); err != nil {
+		return "", err
+	}
+	permit, err := evaluateGuard(d, f)
+	if err != nil {
+		return "", err
+	}
+	if !permit {
+		return SkipGuardFailed, nil
+	}
+	return "", nil
+}
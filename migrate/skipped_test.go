@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSkippedMigrations(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 2",
+		"003_migration3.up.sql":   "SELECT 3",
+		"004_experimental.up.sql": "SELECT 4",
+		"005_migration5.up.sql":   "-- migrate:guard SELECT enabled FROM feature_flags\nSELECT 5",
+		"006_migration6.up.sql":   "SELECT 6",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeQuery.reset()
+	if err := Migrate("fakequery://", tmpdir, 3); err != nil {
+		t.Fatal(err)
+	}
+	// version 2 was rolled back manually
+	delete(fakeQuery.versions, 2)
+
+	SetNameFilter(func(name string) bool { return !strings.HasPrefix(name, "experimental") })
+	defer SetNameFilter(nil)
+	fakeQuery.rows = [][]string{{"false"}}
+
+	skipped, err := SkippedMigrations("fakequery://", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		version uint64
+		reason  SkipReason
+	}{
+		{1, SkipAlreadyApplied},
+		{2, SkipIgnored},
+		{3, SkipAlreadyApplied},
+		{4, SkipFiltered},
+		{5, SkipGuardFailed},
+	}
+	if len(skipped) != len(expect) {
+		t.Fatalf("Expected %v skipped migrations, got %+v", len(expect), skipped)
+	}
+	for i, e := range expect {
+		if skipped[i].File.Version != e.version || skipped[i].Reason != e.reason {
+			t.Errorf("Expected version %v to be skipped as %v, got %v %v", e.version, e.reason, skipped[i].File.Version, skipped[i].Reason)
+		}
+	}
+
+	// a passing guard doesn't skip
+	fakeQuery.rows = [][]string{{"true"}}
+	skipped, err = SkippedMigrations("fakequery://", tmpdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 4 || skipped[3].File.Version != 4 {
+		t.Errorf("Expected version 5 not to be skipped, got %+v", skipped)
+	}
+
+	// guards require a QueryDriver
+	fake.reset()
+	if _, err := SkippedMigrations("fake://", tmpdir); err != ErrQueryNotSupported {
+		t.Errorf("Expected ErrQueryNotSupported, got %v", err)
+	}
+}
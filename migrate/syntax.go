@@ -0,0 +1,42 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chr4/migrate/driver"
+)
+
+// ErrSyntaxCheckNotSupported is returned if the driver doesn't
+// implement driver.SyntaxCheckDriver.
+var ErrSyntaxCheckNotSupported = errors.New("driver does not support syntax checks")
+
+// CheckSyntax validates all pending up migrations without applying
+// them and returns the first error found. What is validated depends
+// on the driver, see driver.SyntaxCheckDriver.
+func CheckSyntax(url, migrationsPath string) error {
+	d, files, version, err := initDriverAndReadMigrationFilesAndGetVersion(url, migrationsPath)
+	if err != nil {
+		return err
+	}
+	defer closeDriver(d)
+
+	checker, ok := d.(driver.SyntaxCheckDriver)
+	if !ok {
+		return ErrSyntaxCheckNotSupported
+	}
+
+	applyMigrationFiles, err := pendingUpFiles(d, files, version)
+	if err != nil {
+		return err
+	}
+	for _, f := range applyMigrationFiles {
+		if err := f.ReadContent(); err != nil {
+			return err
+		}
+		if err := checker.CheckSyntax(f.Content); err != nil {
+			return fmt.Errorf("%s: %v", f.FileName, err)
+		}
+	}
+	return nil
+}
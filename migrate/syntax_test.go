@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCheckSyntax(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "SELECT 1",
+		"002_migration2.down.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	fakeSyntax.reset()
+	if err := CheckSyntax("fakesyntax://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckSyntax("fake://", tmpdir); err != ErrSyntaxCheckNotSupported {
+		t.Errorf("Expected ErrSyntaxCheckNotSupported, got %v", err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(tmpdir, "003_migration3.up.sql"), []byte("FAIL"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckSyntax("fakesyntax://", tmpdir)
+	if err == nil || !strings.Contains(err.Error(), "003_migration3.up.sql") {
+		t.Errorf("Expected syntax error in 003_migration3.up.sql, got %v", err)
+	}
+	if len(fakeSyntax.applied) != 0 {
+		t.Errorf("Expected no applied files, got %v", len(fakeSyntax.applied))
+	}
+}
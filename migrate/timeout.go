@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// runTimeout is an internal variable that holds the
+// maximum duration of a whole run
+var runTimeout time.Duration
+
+// SetRunTimeout limits the time all files of a single Up, Down or Migrate
+// call may take together, e.g. to enforce a deploy deadline. Once timeout
+// has passed, no further files are started. Drivers implementing
+// driver.ContextMigrator also abort the running file. 0 disables the
+// timeout, which is the default.
+func SetRunTimeout(timeout time.Duration) {
+	runTimeout = timeout
+}
+
+// ErrRunTimeout is returned, along with the number of completed
+// files, if a run exceeds the timeout set by SetRunTimeout.
+var ErrRunTimeout = errors.New("run timeout exceeded")
+
+// runContext returns a context which is done once the run timeout
+// has passed. The cancel func must be called at the end of the run.
+func runContext() (context.Context, context.CancelFunc) {
+	if runTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), runTimeout)
+}
+
+// runTimeoutError returns the error of a run which timed
+// out after completing completed of total files.
+func runTimeoutError(completed, total int) error {
+	return fmt.Errorf("%w: %d of %d files completed within %v", ErrRunTimeout, completed, total, runTimeout)
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTimeout(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql":   "SELECT 1",
+		"001_migration1.down.sql": "SELECT 1",
+		"002_migration2.up.sql":   "DEADLINE",
+		"002_migration2.down.sql": "SELECT 2",
+		"003_migration3.up.sql":   "SELECT 3",
+		"003_migration3.down.sql": "DEADLINE",
+		"004_migration4.up.sql":   "SELECT 4",
+		"004_migration4.down.sql": "SELECT 4",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	// files containing DEADLINE complete once the timeout has passed,
+	// so no further files are started, the others take no time
+	SetRunTimeout(100 * time.Millisecond)
+	defer SetRunTimeout(0)
+	delay := fakeSlow.delay
+	fakeSlow.delay = 0
+	defer func() { fakeSlow.delay = delay }()
+
+	fakeSlow.reset()
+	err := Up("fakeslow://", tmpdir)
+	if !errors.Is(err, ErrRunTimeout) || !strings.Contains(err.Error(), "2 of 4 files completed within 100ms") {
+		t.Errorf("Expected run timeout after 2 files, got %v", err)
+	}
+	if version, _ := fakeSlow.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+
+	// the timeout applies to each run
+	if err := Up("fakeslow://", tmpdir); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := fakeSlow.Version(); version != 4 {
+		t.Errorf("Expected version 4, got %v", version)
+	}
+
+	AllowDown(true)
+	defer AllowDown(false)
+	if err := Down("fakeslow://", tmpdir); !errors.Is(err, ErrRunTimeout) {
+		t.Errorf("Expected run timeout, got %v", err)
+	}
+	if version, _ := fakeSlow.Version(); version != 2 {
+		t.Errorf("Expected version 2, got %v", version)
+	}
+}
+
+func TestRunTimeoutBetweenFiles(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 2",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	SetRunTimeout(time.Nanosecond)
+	defer SetRunTimeout(0)
+
+	fake.reset()
+	err := Up("fake://", tmpdir)
+	if !errors.Is(err, ErrRunTimeout) || !strings.Contains(err.Error(), "0 of 2 files completed") {
+		t.Errorf("Expected run timeout before the first file, got %v", err)
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected no files to be started, got %v", fake.applied)
+	}
+}
+
+func TestRunTimeoutDuringFile(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SLOW",
+		"003_migration3.up.sql": "SELECT 3",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	// the second file would take 1s
+	SetRunTimeout(50 * time.Millisecond)
+	defer SetRunTimeout(0)
+
+	fakeSlow.reset()
+	start := time.Now()
+	err := Up("fakeslow://", tmpdir)
+	if !errors.Is(err, ErrRunTimeout) || !strings.Contains(err.Error(), "1 of 3 files completed") {
+		t.Errorf("Expected run timeout during the second file, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the running file to be aborted, took %v", elapsed)
+	}
+	if version, _ := fakeSlow.Version(); version != 1 {
+		t.Errorf("Expected version 1, got %v", version)
+	}
+}
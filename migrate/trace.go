@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chr4/migrate/driver"
+	"github.com/chr4/migrate/file"
+	"github.com/chr4/migrate/migrate/direction"
+)
+
+// Tracer starts spans for distributed tracing, e.g. of deploys. Package
+// migrate/oteltrace adapts an OpenTelemetry tracer, so this package
+// doesn't depend on OpenTelemetry itself.
+type Tracer interface {
+
+	// Start starts a span as child of the span in ctx, if any,
+	// and returns a context holding the new span.
+	Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+// Attribute describes a span. Value is a string or an int64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// tracer is an internal variable that holds the tracer,
+// nil if no spans are started
+var tracer Tracer
+
+// SetTracer sets the tracer. Each run becomes a "migrate" span from
+// acquiring the lock until the driver is closed, each migrated file a
+// "migrate.file" child span. Pass nil to stop tracing.
+func SetTracer(t Tracer) {
+	tracer = t
+}
+
+// tracedRun is the span of a run and its context
+type tracedRun struct {
+	ctx  context.Context
+	span Span
+}
+
+var (
+	tracedRunsMu sync.Mutex
+	tracedRuns   = make(map[driver.Driver]*tracedRun)
+)
+
+// startRunSpan starts the span of a run on d starting at version.
+func startRunSpan(d driver.Driver, version uint64) {
+	if tracer == nil {
+		return
+	}
+	ctx, span := tracer.Start(context.Background(), "migrate",
+		Attribute{"migrate.start_version", int64(version)})
+
+	tracedRunsMu.Lock()
+	defer tracedRunsMu.Unlock()
+	tracedRuns[d] = &tracedRun{ctx: ctx, span: span}
+}
+
+// endRunSpan ends the span of the run on d, if any.
+func endRunSpan(d driver.Driver) {
+	tracedRunsMu.Lock()
+	run, ok := tracedRuns[d]
+	delete(tracedRuns, d)
+	tracedRunsMu.Unlock()
+	if ok {
+		run.span.End()
+	}
+}
+
+// traceFile starts the span of migrating f on d. The returned
+// func records the error, if any, and ends the span.
+func traceFile(d driver.Driver, f file.File) func(err error) {
+	if tracer == nil {
+		return func(err error) {}
+	}
+
+	tracedRunsMu.Lock()
+	run := tracedRuns[d]
+	tracedRunsMu.Unlock()
+	ctx := context.Background()
+	if run != nil {
+		ctx = run.ctx
+	}
+
+	name := "up"
+	if f.Direction == direction.Down {
+		name = "down"
+	}
+	_, span := tracer.Start(ctx, "migrate.file",
+		Attribute{"migrate.version", int64(f.Version)},
+		Attribute{"migrate.name", f.Name},
+		Attribute{"migrate.direction", name})
+
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			if run != nil {
+				run.span.RecordError(err)
+			}
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// fakeSpan is a span recorded by fakeTracer
+type fakeSpan struct {
+	name       string
+	parent     *fakeSpan
+	attributes map[string]interface{}
+	errs       []error
+	ended      bool
+}
+
+func (s *fakeSpan) RecordError(err error) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *fakeSpan) End() {
+	s.ended = true
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer records all started spans in order
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	span := &fakeSpan{name: name, attributes: make(map[string]interface{})}
+	span.parent, _ = ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	for _, a := range attributes {
+		span.attributes[a.Key] = a.Value
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func TestTracer(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+		"002_migration2.up.sql": "SELECT 1",
+		"003_migration3.up.sql": "FAIL",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	tracer := &fakeTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	fake.reset()
+	if err := Up("fake://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+
+	if len(tracer.spans) != 4 {
+		t.Fatalf("Expected a run span and 3 file spans, got %v", len(tracer.spans))
+	}
+	run := tracer.spans[0]
+	if run.name != "migrate" || run.parent != nil || run.attributes["migrate.start_version"] != int64(0) {
+		t.Errorf("Expected root span of the run, got %+v", run)
+	}
+	if len(run.errs) != 1 || !run.ended {
+		t.Errorf("Expected ended run span with an error, got %+v", run)
+	}
+	for i, span := range tracer.spans[1:] {
+		version := int64(i + 1)
+		if span.name != "migrate.file" || span.parent != run || !span.ended {
+			t.Errorf("Expected ended file span as child of the run span, got %+v", span)
+		}
+		if span.attributes["migrate.version"] != version || span.attributes["migrate.name"] != fmt.Sprintf("migration%d", version) || span.attributes["migrate.direction"] != "up" {
+			t.Errorf("Expected attributes of version %v, got %v", version, span.attributes)
+		}
+		if failed := len(span.errs) > 0; failed != (version == 3) {
+			t.Errorf("Expected only version 3 to record an error, got %v for version %v", span.errs, version)
+		}
+	}
+
+	// no tracer, no spans
+	SetTracer(nil)
+	fake.reset()
+	if err := Up("fake://", tmpdir); err == nil {
+		t.Fatal("Expected version 3 to fail")
+	}
+	if len(tracer.spans) != 4 {
+		t.Errorf("Expected no more spans, got %v", len(tracer.spans))
+	}
+}
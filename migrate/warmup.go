@@ -0,0 +1,30 @@
+package migrate
+
+import (
+	"github.com/chr4/migrate/driver"
+)
+
+// Warmup connects to the database ahead of migrating, e.g. in short-lived
+// runners where the latency of the first statement matters. Drivers
+// implementing driver.Warmer also load caches and keep the connection
+// open for the next migration function using the same url, which closes
+// it. Other drivers run a trivial query and are closed again, so Warmup
+// only checks that the database is reachable.
+func Warmup(url string) error {
+	d, err := driver.New(url)
+	if err != nil {
+		return err
+	}
+	if w, ok := d.(driver.Warmer); ok {
+		if err := w.Warmup(); err != nil {
+			d.Close()
+			return err
+		}
+		return nil
+	}
+	if _, err := d.Version(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	fake.reset()
+	if err := Warmup("fake://"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.applied) != 0 {
+		t.Errorf("Expected no migrations to run, got %v", fake.applied)
+	}
+	if fake.closed != 1 {
+		t.Errorf("Expected the driver to be closed, got %v calls of Close", fake.closed)
+	}
+}
+
+func TestWarmupPostgres(t *testing.T) {
+	tmpdir := writeMigrationFiles(t, map[string]string{
+		"001_migration1.up.sql": "SELECT 1",
+	})
+	defer os.RemoveAll(tmpdir)
+
+	for _, driverUrl := range driverUrls {
+		if err := Warmup(driverUrl); err != nil {
+			t.Fatal(err)
+		}
+
+		// a second call reuses the warm connection
+		start := time.Now()
+		if err := Warmup(driverUrl); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Expected second warm-up to be quick, took %v", d)
+		}
+
+		// the connection is usable for migrating
+		if err := Up(driverUrl, tmpdir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}